@@ -11,10 +11,11 @@ import (
 
 var clearCmd = &cobra.Command{
 	Use:   "clear",
-	Short: "Clear all temporary files, in case something stuck",
+	Short: "Clear all temporary files, including partial downloads, in case something stuck",
 	Run: func(cmd *cobra.Command, args []string) {
 
 		shared.ClearActiveDownloads()
+		shared.CleanupTempDirs()
 
 		fmt.Println("✅ Cleared temporary files.")
 	},
@@ -2,10 +2,14 @@ package cmd
 
 import (
 	"fmt"
+	"math"
 	"os"
 	"strconv"
+	"strings"
 
+	"opforjellyfin/internal/flags"
 	"opforjellyfin/internal/logger"
+	"opforjellyfin/internal/metadata"
 	"opforjellyfin/internal/scraper"
 	"opforjellyfin/internal/shared"
 	"opforjellyfin/internal/torrent"
@@ -17,18 +21,46 @@ import (
 var (
 	forceKey string
 	seed     bool
+
+	arcSelector    string
+	rangeSelector  string
+	seasonSelector int
+	missingOnly    bool
+	qualityOnly    = flags.StringChoice([]string{"480p", "720p", "1080p"})
+	minQuality     = flags.StringChoice([]string{"480p", "720p", "1080p"})
+	assumeYes      bool
+	upgrading      bool
+
+	maxConcurrent int
+	downloadLimit int
+	uploadLimit   int
+	listenPort    int
+	placementMode = flags.StringChoice([]string{shared.PlacementMove, shared.PlacementHardlink})
 )
 
 var downloadCmd = &cobra.Command{
-	Use:   "download <downloadKey> [downloadKey...]",
-	Short: "Download one or more One Pace torrents",
+	Use:   "download [downloadKey...]",
+	Short: "Download One Pace torrents by key, arc, chapter range or season",
 	Run: func(cmd *cobra.Command, args []string) {
 
 		// add spinner
 		spinner := ui.NewSpinner("🗃️ Preparing download.. ", ui.Animations["MetaFetcher"])
 
-		if len(args) < 1 {
-			logger.Log(true, "⚠️ You must specify atleast one download-key")
+		selecting := missingOnly || cmd.Flags().Changed("arc") || cmd.Flags().Changed("range") || cmd.Flags().Changed("season")
+
+		// --upgrade on its own fetches every upgrade 'upgrades' would list
+		allUpgrades := upgrading && len(args) < 1 && !selecting
+
+		if len(args) < 1 && !selecting && !allUpgrades {
+			logger.Log(true, "⚠️ You must specify atleast one download-key, or use --arc, --range, --season, --missing or --upgrade")
+			return
+		}
+		if len(args) > 0 && selecting {
+			logger.Log(true, "❌ Use either download-keys or --arc/--range/--season/--missing, not both")
+			return
+		}
+		if selecting && forceKey != "" {
+			logger.Log(true, "❌ --forcekey may only be used with a single DownloadKey")
 			return
 		}
 
@@ -38,57 +70,71 @@ var downloadCmd = &cobra.Command{
 			return
 		}
 
-		if cfg.Source.BaseURL == "" {
+		if len(cfg.AllSources()) == 0 {
 			logger.Log(true, "No valid scraper configuration found. Please run 'sync'")
 		}
 
-		searchCache, err := scraper.LoadSearchCache()
-		if err != nil {
-			logger.Log(true, "❌ Error loading search cache. Did you run 'list'? - %v", err)
-			return
-		}
-
-		// stop spinner
-		spinner.Stop()
 		var matches []shared.TorrentEntry
-		for _, arg := range args {
-			num, err := strconv.Atoi(arg)
+		if allUpgrades {
+			upgrades, err := findUpgrades(cfg)
+			spinner.Stop()
 			if err != nil {
-				logger.Log(true, "❌ Invalid syntax: %s", arg)
+				logger.Log(true, "❌ %v", err)
 				return
 			}
+			if len(upgrades) == 0 {
+				fmt.Println("✅ Nothing to upgrade.")
+				return
+			}
+			for _, up := range upgrades {
+				printUpgrade(up)
+				matches = append(matches, up.Release)
+			}
+		} else if selecting {
+			selected, bundles, err := selectMatches(cfg)
+			spinner.Stop()
+			if err != nil {
+				logger.Log(true, "❌ %v", err)
+				return
+			}
+			matches = selected
 
-			// sort
-			var match *shared.TorrentEntry
-			for _, t := range searchCache.Results {
-				if t.DownloadKey == num {
-					if match == nil || t.Seeders > match.Seeders {
-						tmp := t
-						match = &tmp
-					}
+			if missingOnly {
+				if len(matches) == 0 {
+					fmt.Println("✅ Nothing missing - you have every range there is metadata for.")
+					return
+				}
+				if !confirmPlan(cfg, matches, bundles) {
+					fmt.Println("🚫 Nothing downloaded.")
+					return
 				}
 			}
-
-			// no match for download-key
-			if match == nil {
-				logger.Log(true, "⚠️  No torrent found for key %d", num)
-				continue
+		} else {
+			searchCache, err := scraper.LoadSearchCache()
+			if err != nil {
+				logger.Log(true, "❌ Error loading search cache. Did you run 'list'? - %v", err)
+				return
 			}
 
-			// maybe rewrite this part
-			if forceKey != "" {
-				if len(args) > 1 {
-					logger.Log(true, "❌ --forcekey may only be used with a single DownloadKey")
-				}
-				match.ChapterRange = forceKey
+			// stop spinner
+			spinner.Stop()
+
+			matched, ok := matchKeys(cfg, searchCache, args)
+			if !ok {
+				return
 			}
+			matches = matched
+		}
 
-			dKey := ui.StyleFactory(fmt.Sprintf("%4d", match.DownloadKey), ui.Style.Pink)
-			title := ui.StyleFactory(match.TorrentName, ui.Style.LBlue)
+		// --missing and --upgrade have shown them already
+		if !missingOnly && !allUpgrades {
+			for _, match := range matches {
+				dKey := ui.StyleFactory(fmt.Sprintf("%4d", match.DownloadKey), ui.Style.Pink)
+				title := ui.StyleFactory(match.TorrentName, ui.Style.LBlue)
 
-			logger.Log(true, "🔍 Matched DownloadKey %s → %s (%s) [%s]", dKey, title, match.Quality, match.ChapterRange)
-			logger.Log(true, "🎬 Starting download: %s (%s)\n", match.TorrentName, match.Quality)
-			matches = append(matches, *match)
+				logger.Log(true, "🔍 Matched DownloadKey %s → %s (%s) [%s]", dKey, title, match.Quality, match.ChapterRange)
+				logger.Log(true, "🎬 Starting download: %s (%s)\n", match.TorrentName, match.Quality)
+			}
 		}
 
 		if len(matches) == 0 {
@@ -96,19 +142,272 @@ var downloadCmd = &cobra.Command{
 			os.Exit(0)
 		}
 
-		if seed && len(matches) > torrent.MaxConcurrent {
-			logger.Log(true, "⚠️  --seed with more than %d keys: only the first %d will start at all this run - a seeding worker never frees up to pick up the rest until you stop with Ctrl+C.", torrent.MaxConcurrent, torrent.MaxConcurrent)
+		applyDownloadFlags(cmd, cfg)
+
+		if maxConc := torrent.MaxConcurrent(); seed && len(matches) > maxConc {
+			logger.Log(true, "⚠️  --seed with more than %d keys: only the first %d will start at all this run - a seeding worker never frees up to pick up the rest until you stop with Ctrl+C.", maxConc, maxConc)
 		}
 
 		// outsourced to monitoring function
-		torrent.HandleDownloadSession(matches, cfg.TargetDir, seed)
+		torrent.HandleDownloadSession(matches, cfg.TargetDir, torrent.SessionOptions{Seed: seed, UpgradeOnly: upgrading})
 
 	},
 }
 
+// looks the download-keys up in the search cache. Returns false if the
+// download should not go ahead
+func matchKeys(cfg *shared.Config, searchCache *scraper.SearchCache, args []string) ([]shared.TorrentEntry, bool) {
+	// keys are only valid for the sources that produced them
+	if !searchCache.MatchesConfig(cfg) {
+		logger.Log(true, "❌ Your sources have changed since the last 'list'. Download keys may point at different torrents now - run 'list' again.")
+		return nil, false
+	}
+	if searchCache.IsStale() {
+		age := "of unknown age"
+		if !searchCache.FetchedAt.IsZero() {
+			age = ui.FormatAge(searchCache.Age()) + " old"
+		}
+		logger.Log(true, "⚠️ The search cache is %s. Keys may have shifted since - run 'list' to refresh if the match below looks wrong.", age)
+	}
+
+	var matches []shared.TorrentEntry
+	for _, arg := range args {
+		num, err := strconv.Atoi(arg)
+		if err != nil {
+			logger.Log(true, "❌ Invalid syntax: %s", arg)
+			return nil, false
+		}
+
+		// sort
+		var match *shared.TorrentEntry
+		for _, t := range searchCache.Results {
+			if t.DownloadKey == num {
+				if match == nil || t.Seeders > match.Seeders {
+					tmp := t
+					match = &tmp
+				}
+			}
+		}
+
+		// no match for download-key
+		if match == nil {
+			logger.Log(true, "⚠️  No torrent found for key %d", num)
+			continue
+		}
+
+		// maybe rewrite this part
+		if forceKey != "" {
+			if len(args) > 1 {
+				logger.Log(true, "❌ --forcekey may only be used with a single DownloadKey")
+			}
+			match.ChapterRange = forceKey
+		}
+
+		matches = append(matches, *match)
+	}
+
+	return matches, true
+}
+
+// resolves --arc, --range, --season and --missing to the best release per
+// chapter range. bundles maps the download key of each picked release that
+// reaches past the range asked for to that range
+func selectMatches(cfg *shared.Config) (matches []shared.TorrentEntry, bundles map[int]string, err error) {
+	ranges, err := selectorRanges()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// --missing alone means everything
+	if len(ranges) == 0 {
+		ranges = []string{fmt.Sprintf("1-%d", math.MaxInt32)}
+	}
+
+	results, err := searchResults(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var entries []shared.TorrentEntry
+	for _, e := range results {
+		if qualityOnly.Value != "" && e.Quality != qualityOnly.Value {
+			continue
+		}
+		if minQuality.Value != "" && shared.QualityValue(e.Quality) < shared.QualityValue(minQuality.Value) {
+			continue
+		}
+		// ranges we have metadata for but not all the videos
+		if missingOnly && (!e.MetaDataAvail || e.HaveIt == 2) {
+			continue
+		}
+		entries = append(entries, e)
+	}
+
+	seen := make(map[int]bool)
+	bundles = make(map[int]string)
+	for _, r := range ranges {
+		lo, hi := shared.ParseRange(r)
+		for _, e := range scraper.SelectForRange(entries, lo, hi, qualityOnly.Value) {
+			// only a bundle if it is one for every range it was picked for
+			if !scraper.Contained(e, lo, hi) {
+				if !seen[e.DownloadKey] {
+					bundles[e.DownloadKey] = r
+				}
+			} else {
+				delete(bundles, e.DownloadKey)
+			}
+
+			if !seen[e.DownloadKey] {
+				seen[e.DownloadKey] = true
+				matches = append(matches, e)
+			}
+		}
+	}
+
+	return matches, bundles, nil
+}
+
+// the chapter ranges asked for with --arc, --range and --season
+func selectorRanges() ([]string, error) {
+	var ranges []string
+
+	if arcSelector != "" {
+		seasons := metadata.FindSeasonsByName(arcSelector)
+		if len(seasons) == 0 {
+			return nil, fmt.Errorf("no arc named like %q in the metadata. Did you run 'sync'?", arcSelector)
+		}
+		for _, season := range seasons {
+			logger.Log(false, "arc %q matched %s (%s)", arcSelector, season.Name, season.Range)
+			ranges = append(ranges, season.Range)
+		}
+	}
+
+	if rangeSelector != "" {
+		r := rangeSelector
+		if !strings.Contains(r, "-") {
+			r = r + "-" + r
+		}
+		lo, hi := shared.ParseRange(r)
+		if lo <= 0 || hi < lo {
+			return nil, fmt.Errorf("invalid chapter range %q, expected e.g. 900-1000", rangeSelector)
+		}
+		ranges = append(ranges, r)
+	}
+
+	if seasonSelector > 0 {
+		season, ok := metadata.FindSeasonByNumber(seasonSelector)
+		if !ok {
+			return nil, fmt.Errorf("no season %d in the metadata. Did you run 'sync'?", seasonSelector)
+		}
+		ranges = append(ranges, season.Range)
+	}
+
+	return ranges, nil
+}
+
+// the last search's results if they are fresh and from the current sources,
+// else a new search
+func searchResults(cfg *shared.Config) ([]shared.TorrentEntry, error) {
+	if cache, err := scraper.LoadSearchCache(); err == nil && cache.ConfigHash != "" && !cache.IsStale() && cache.MatchesConfig(cfg) {
+		return cache.CachedResults(), nil
+	}
+
+	entries, err := scraper.FetchTorrents(cfg)
+	if err != nil && len(entries) == 0 {
+		return nil, fmt.Errorf("error scraping torrents. Site inaccessible? %w", err)
+	}
+	if err != nil {
+		logger.Log(true, "⚠️ Some sources failed, picking from the rest: %v", err)
+	}
+
+	return entries, nil
+}
+
+// shows what --missing is about to download and asks to go ahead, unless --yes.
+// bundles are marked with the range they were picked for, see selectMatches
+func confirmPlan(cfg *shared.Config, matches []shared.TorrentEntry, bundles map[int]string) bool {
+	spinner := ui.NewSpinner("📏 Getting torrent sizes.. ", ui.Animations["MetaFetcher"])
+	scraper.ResolveSizes(cfg, matches)
+	spinner.Stop()
+
+	var total int64
+	unknown := 0
+
+	fmt.Println("📋 Missing, will download:")
+	for _, m := range matches {
+		size := "?"
+		if m.Size > 0 {
+			size = ui.FormatBytes(m.Size)
+			total += m.Size
+		} else {
+			unknown++
+		}
+
+		bundle := ""
+		if r, ok := bundles[m.DownloadKey]; ok {
+			bundle = "  " + ui.StyleFactory(fmt.Sprintf("📦 bundle, reaches past %s for chapters nothing within covers", r), ui.Style.Pink)
+		}
+
+		fmt.Printf("   %s  %s (%s) [%s]  %s%s\n",
+			ui.StyleFactory(fmt.Sprintf("%4d", m.DownloadKey), ui.Style.Pink),
+			ui.StyleFactory(m.TorrentName, ui.Style.LBlue),
+			m.Quality, m.ChapterRange, size, bundle)
+	}
+
+	totalMsg := ui.FormatBytes(total)
+	if unknown > 0 {
+		totalMsg = fmt.Sprintf("at least %s (%d of unknown size)", totalMsg, unknown)
+	}
+	fmt.Printf("📦 %d torrents, %s\n", len(matches), totalMsg)
+
+	if assumeYes {
+		return true
+	}
+
+	return ui.Confirm("Download all of these?")
+}
+
+// registers the session flags shared by commands that start downloads
+func addDownloadFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&seed, "seed", false, "Keep seeding downloaded torrents until you stop the program (Ctrl+C)")
+	cmd.Flags().IntVar(&maxConcurrent, "max-concurrent", 0, "Number of torrents downloaded at once (default from config, or 5)")
+	cmd.Flags().IntVar(&downloadLimit, "download-limit", 0, "Download rate limit in KiB/s, 0 = unlimited (default from config)")
+	cmd.Flags().IntVar(&uploadLimit, "upload-limit", 0, "Upload rate limit in KiB/s, 0 = unlimited (default from config)")
+	cmd.Flags().IntVar(&listenPort, "port", 0, "Fixed torrent listen port for port forwarding (default from config, or random)")
+	cmd.Flags().Var(placementMode, "placement", "How videos are put in the library: move, or hardlink to keep seeding them (default from config, or move)")
+}
+
+// overrides the config's download settings with any flags set on cmd. Only
+// for this run - the config file isn't written.
+func applyDownloadFlags(cmd *cobra.Command, cfg *shared.Config) {
+	if cmd.Flags().Changed("max-concurrent") {
+		cfg.Download.MaxConcurrent = maxConcurrent
+	}
+	if cmd.Flags().Changed("download-limit") {
+		cfg.Download.DownloadLimit = downloadLimit
+	}
+	if cmd.Flags().Changed("upload-limit") {
+		cfg.Download.UploadLimit = uploadLimit
+	}
+	if cmd.Flags().Changed("port") {
+		cfg.Download.ListenPort = listenPort
+	}
+	if cmd.Flags().Changed("placement") {
+		cfg.Placement = placementMode.Value
+	}
+}
+
 func init() {
 	downloadCmd.Flags().StringVar(&forceKey, "forcekey", "", "Override chapter range (only for single downloadKey)")
-	downloadCmd.Flags().BoolVar(&seed, "seed", false, "Keep seeding downloaded torrents until you stop the program (Ctrl+C)")
+	downloadCmd.Flags().StringVar(&arcSelector, "arc", "", "Download an arc by name, e.g. Wano")
+	downloadCmd.Flags().StringVar(&rangeSelector, "range", "", "Download a chapter range, e.g. 900-1000")
+	downloadCmd.Flags().IntVar(&seasonSelector, "season", 0, "Download a season by number, e.g. 35")
+	downloadCmd.Flags().BoolVar(&missingOnly, "missing", false, "Download every range you have metadata for but not all videos of")
+	downloadCmd.Flags().Var(qualityOnly, "quality", "Only pick releases in this quality (default highest available)")
+	downloadCmd.Flags().Var(minQuality, "minquality", "Only pick releases of at least this quality, e.g. 720p")
+	downloadCmd.Flags().BoolVar(&upgrading, "upgrade", false, "Only replace videos in your library with better quality ones. Without keys, downloads every upgrade 'upgrades' lists")
+	downloadCmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "Don't ask before downloading everything --missing found")
+	addDownloadFlags(downloadCmd)
 
 	rootCmd.AddCommand(downloadCmd)
 }
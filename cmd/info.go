@@ -10,6 +10,7 @@ import (
 	"path/filepath"
 	"sort"
 	"strconv"
+	"time"
 
 	"github.com/spf13/cobra"
 )
@@ -42,6 +43,8 @@ var infoCmd = &cobra.Command{
 			return
 		}
 
+		printRevision(cfg.TargetDir)
+
 		if verboseInfo {
 			fmt.Printf("📡 Torrent Provider: %s\n", cfg.Source.BaseURL)
 			fmt.Printf("🐙 Metadata Source:  https://github.com/%s\n", cfg.GitHubRepo)
@@ -95,6 +98,21 @@ var infoCmd = &cobra.Command{
 	},
 }
 
+// prints the installed metadata revision and when it was synced
+func printRevision(baseDir string) {
+	rev, err := metadata.LoadRevision(baseDir)
+	if err != nil {
+		fmt.Printf("⚠️  Could not read metadata revision: %v\n", err)
+		return
+	}
+	if rev == nil {
+		fmt.Println("📌 Metadata Revision: unknown (run 'opfor sync' to record it)")
+		return
+	}
+
+	fmt.Printf("📌 Metadata Revision: %s @ %s, synced %s ago\n", rev.RefName(), rev.Short(), ui.FormatAge(time.Since(rev.SyncedAt)))
+}
+
 func styleSeasonPrint(s season) string {
 
 	vidStr := fmt.Sprintf("%4d", s.videos)
@@ -4,10 +4,10 @@ package cmd
 import (
 	"fmt"
 	"sort"
-	"strconv"
 	"strings"
 
 	"opforjellyfin/internal/flags"
+	"opforjellyfin/internal/library"
 	"opforjellyfin/internal/logger"
 	"opforjellyfin/internal/scraper"
 	"opforjellyfin/internal/shared"
@@ -26,56 +26,120 @@ var (
 	verboseList  bool
 
 	alternate bool
+
+	cachedList bool
 )
 
 var listCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List all available One Pace seasons and specials",
 	Run: func(cmd *cobra.Command, args []string) {
+		if cachedList {
+			listCached()
+			return
+		}
+
 		spinner := ui.NewMultirowSpinner(ui.Animations["Searcher"], 4)
 
 		cfg, _ := shared.LoadConfig()
 
-		if cfg.Source.BaseURL == "" {
+		if len(cfg.AllSources()) == 0 {
 			spinner.Stop()
 			logger.Log(true, "⚠️ No valid scraper configuration found. Please run 'sync' or 'setDir'")
 			return
 		}
 
+		// an error alongside results means only some sources failed
 		allTorrents, err := scraper.FetchTorrents(cfg)
-		if err != nil {
+		if err != nil && len(allTorrents) == 0 {
 			spinner.Stop()
 			logger.Log(true, "❌ Error scraping torrents. Site inaccessible? %v", err)
 			return
 		}
 
-		// Apply filters after keys are assigned
-		var filtered []shared.TorrentEntry
-		for _, t := range allTorrents {
-			if applyFilters(t) {
-				filtered = append(filtered, t)
-			}
+		spinner.Stop()
+
+		if err != nil {
+			logger.Log(true, "⚠️ Some sources failed, showing results from the rest: %v", err)
 		}
 
-		// Sort by DownloadKey, then seeders descending
-		sort.SliceStable(filtered, func(i, j int) bool {
-			if filtered[i].DownloadKey == filtered[j].DownloadKey {
-				return filtered[i].Seeders > filtered[j].Seeders
-			}
-			return filtered[i].DownloadKey < filtered[j].DownloadKey
-		})
+		renderList(allTorrents)
+	},
+}
 
-		spinner.Stop()
+// renders the last search from cache instead of scraping
+func listCached() {
+	cache, err := scraper.LoadSearchCache()
+	if err != nil {
+		logger.Log(true, "❌ No cached search to show. Run 'list' without --cached first. %v", err)
+		return
+	}
+
+	var sources []string
+	for _, src := range cache.Sources {
+		sources = append(sources, fmt.Sprintf("%s (%q)", src.Name, src.Query))
+	}
+
+	age := "unknown age"
+	if !cache.FetchedAt.IsZero() {
+		age = "fetched " + ui.FormatAge(cache.Age()) + " ago"
+	}
+	if len(sources) > 0 {
+		logger.Log(true, "🗂️ Cached results from %s, %s", strings.Join(sources, ", "), age)
+	} else {
+		logger.Log(true, "🗂️ Cached results, %s", age)
+	}
+
+	cfg, _ := shared.LoadConfig()
+	if !cache.MatchesConfig(cfg) {
+		logger.Log(true, "⚠️ Your sources have changed since this search. Run 'list' to refresh.")
+	}
+
+	renderList(cache.CachedResults())
+}
+
+// filters, sorts and prints entries
+func renderList(allTorrents []shared.TorrentEntry) {
+	// Apply filters after keys are assigned
+	var filtered []shared.TorrentEntry
+	for _, t := range allTorrents {
+		if applyFilters(t) {
+			filtered = append(filtered, t)
+		}
+	}
+
+	// Sort by DownloadKey, then seeders descending
+	sort.SliceStable(filtered, func(i, j int) bool {
+		if filtered[i].DownloadKey == filtered[j].DownloadKey {
+			return filtered[i].Seeders > filtered[j].Seeders
+		}
+		return filtered[i].DownloadKey < filtered[j].DownloadKey
+	})
+
+	// to flag re-released versions of what we have
+	lib, err := library.Load()
+	if err != nil {
+		logger.Log(false, "list: could not load library: %v", err)
+	}
 
-		fmt.Println("📚 Filtered Download List:")
-		for _, t := range filtered {
-			if verboseList {
-				renderVerboseRow(t)
-			} else {
-				renderRow(t)
+	fmt.Println("📚 Filtered Download List:")
+	for _, t := range filtered {
+		note := ""
+		if t.HaveIt > 0 && lib.HasNewerVersion(t) {
+			// same version, but another CRC32 than what we have
+			release := "new upload"
+			if t.Version > 1 {
+				release = fmt.Sprintf("v%d", t.Version)
 			}
+			note = ui.StyleFactory(" | 🆕 "+release, ui.Style.Pink)
 		}
-	},
+
+		if verboseList {
+			renderVerboseRow(t, note)
+		} else {
+			renderRow(t, note)
+		}
+	}
 }
 
 func applyFilters(t shared.TorrentEntry) bool {
@@ -108,20 +172,15 @@ func applyFilters(t shared.TorrentEntry) bool {
 		return false
 	}
 
-	if minimumQualityFilter.String() != "" {
-		// Not sure about error checking here, both values should be guaranteed parsable if everything else works as intended.
-		quality, _ := strconv.Atoi(strings.TrimSuffix(t.Quality, "p"))
-		minimumQuality, _ := strconv.Atoi(strings.TrimSuffix(minimumQualityFilter.Value, "p"))
-		if quality < minimumQuality {
-			return false
-		}
+	if minimumQualityFilter.String() != "" && shared.QualityValue(t.Quality) < shared.QualityValue(minimumQualityFilter.Value) {
+		return false
 	}
 
 	return true
 }
 
-// rowrender
-func renderRow(t shared.TorrentEntry) {
+// rowrender, note is appended at the end
+func renderRow(t shared.TorrentEntry, note string) {
 	// bools
 	metaMark := "❌"
 
@@ -139,7 +198,7 @@ func renderRow(t shared.TorrentEntry) {
 	truncatedTitle := ui.AnsiPadRight(t.TorrentName, 30)
 
 	row := ui.RenderRow(
-		"%s - %s: %s Have? %s | Meta: %s | %-9s | %s | %s seeders | %s",
+		"%s - %s: %s Have? %s | Meta: %s | %-9s | %s | %s seeders | %s%s",
 		alternate,
 		ui.StyleFactory("DKEY", ui.Style.LBlue),
 		ui.StyleFactory(fmt.Sprintf("%4d", t.DownloadKey), ui.Style.Pink),
@@ -148,8 +207,9 @@ func renderRow(t shared.TorrentEntry) {
 		metaMark,
 		t.ChapterRange,
 		ui.AnsiPadLeft(ui.StyleByRange(t.Quality, 400, 1000), 5),
-		ui.AnsiPadLeft(ui.StyleByRange(t.Seeders, 0, 10), 3),
+		ui.AnsiPadLeft(seedersCell(t), 3),
 		t.Date,
+		note,
 	)
 
 	// set flag
@@ -159,7 +219,7 @@ func renderRow(t shared.TorrentEntry) {
 }
 
 // render verbose row
-func renderVerboseRow(t shared.TorrentEntry) {
+func renderVerboseRow(t shared.TorrentEntry, note string) {
 	metaMark := "❌"
 	if t.MetaDataAvail {
 		metaMark = "✅"
@@ -174,21 +234,30 @@ func renderVerboseRow(t shared.TorrentEntry) {
 	fullTitle := ui.AnsiPadRight(t.Title, 60)
 
 	row := ui.RenderRow(
-		"%s - %s: %s H:%s M:%s | %s seeders | %s",
+		"%s - %s: %s H:%s M:%s | %s seeders | %s%s",
 		alternate,
 		ui.StyleFactory("DKEY", ui.Style.LBlue),
 		ui.StyleFactory(fmt.Sprintf("%4d", t.DownloadKey), ui.Style.Pink),
 		ui.StyleFactory(fullTitle, ui.Style.LBlue),
 		haveMark,
 		metaMark,
-		ui.AnsiPadLeft(ui.StyleByRange(t.Seeders, 0, 10), 3),
+		ui.AnsiPadLeft(seedersCell(t), 3),
 		t.Date,
+		note,
 	)
 
 	alternate = !alternate
 	fmt.Println(row)
 }
 
+// seeders styled by count, "?" when the source doesn't tell
+func seedersCell(t shared.TorrentEntry) string {
+	if t.Seeders < 0 {
+		return "?"
+	}
+	return ui.StyleByRange(t.Seeders, 0, 10)
+}
+
 // init
 func init() {
 	listCmd.Flags().StringVarP(&rangeFilter, "range", "r", "", "Show seasons in range, e.g. 10-20")
@@ -198,5 +267,6 @@ func init() {
 
 	listCmd.Flags().BoolVarP(&onlySpecials, "specials", "s", false, "Show only specials")
 	listCmd.Flags().BoolVarP(&verboseList, "verbose", "v", false, "Show full titles")
+	listCmd.Flags().BoolVar(&cachedList, "cached", false, "Show the last search again without going online")
 	rootCmd.AddCommand(listCmd)
 }
@@ -0,0 +1,116 @@
+// cmd/prune.go
+package cmd
+
+import (
+	"fmt"
+
+	"opforjellyfin/internal/metadata"
+	"opforjellyfin/internal/prune"
+	"opforjellyfin/internal/shared"
+	"opforjellyfin/internal/ui"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	pruneVideos  bool
+	prunePartial bool
+	pruneYes     bool
+)
+
+var pruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove stale metadata, empty folders and leftover temp files",
+	Long: `Lists and removes .nfo files the metadata repo no longer has, folders left
+empty, and temp files of finished or failed runs. Videos without a matching
+.nfo are only listed unless --videos is given, and partial downloads are kept
+for 'resume' unless --partial is given.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, _ := shared.LoadConfig()
+		if cfg.TargetDir == "" {
+			fmt.Println("⚠️  No target directory set. Use 'setDir' first.")
+			return
+		}
+
+		upstream, rev, err := metadata.UpstreamFiles(cfg)
+		if err != nil {
+			fmt.Printf("⚠️  Could not get the metadata to compare with: %v\n", err)
+			return
+		}
+		fmt.Printf("🔍 Comparing against %s @ %s\n", rev.RefName(), rev.Short())
+
+		plan, err := prune.Find(cfg.TargetDir, upstream, prune.Options{Videos: pruneVideos, Partial: prunePartial})
+		if err != nil {
+			fmt.Printf("❌ Could not scan %s: %v\n", cfg.TargetDir, err)
+			return
+		}
+
+		printPrunePlan(plan)
+
+		removals := plan.Removals()
+		if len(removals) == 0 {
+			fmt.Println("✅ Nothing to prune.")
+			return
+		}
+
+		if !pruneYes && !ui.Confirm(fmt.Sprintf("\nRemove these %d items?", len(removals))) {
+			fmt.Println("Nothing removed.")
+			return
+		}
+
+		n, err := plan.Apply()
+		if err != nil {
+			fmt.Printf("⚠️  Some items could not be removed: %v\n", err)
+		}
+		fmt.Printf("🧹 Removed %d of %d items.\n", n, len(removals))
+	},
+}
+
+// lists everything found, marking what stays
+func printPrunePlan(plan *prune.Plan) {
+	section := func(header string, paths []string, kept string) {
+		if len(paths) == 0 {
+			return
+		}
+		if kept != "" {
+			header += " - " + ui.StyleFactory("kept, "+kept, ui.Style.Pink)
+		}
+		fmt.Printf("\n%s (%d):\n", header, len(paths))
+
+		mark := "-"
+		if kept != "" {
+			mark = " "
+		}
+		for _, p := range paths {
+			fmt.Printf("   %s %s\n", mark, p)
+		}
+	}
+
+	videosKept, partialKept := "", ""
+	if !pruneVideos {
+		videosKept = "use --videos to remove"
+	}
+	if !prunePartial {
+		partialKept = "use --partial to remove, or 'resume' to finish them"
+	}
+
+	section("🗑️  Stale .nfo files", plan.StaleNFO, "")
+	section("📎 Stale .nfo files of kept videos", plan.KeptNFO, "use --videos to remove with their video")
+	section("🎬 Videos without an upstream .nfo", plan.OrphanVideos, videosKept)
+	section("🧹 Temp leftovers", plan.Leftovers, "")
+	section("⏸️  Partial downloads", plan.Partials, partialKept)
+	section("📁 Empty folders", plan.EmptyDirs, "")
+	section("✏️  Files of an interrupted rename", plan.Renaming, "drop the .opfor-rename suffix to restore them")
+
+	if plan.Busy {
+		fmt.Println("\n⏳ A download session is running, temp files are left alone.")
+	}
+	fmt.Println()
+}
+
+func init() {
+	pruneCmd.Flags().BoolVar(&pruneVideos, "videos", false, "Also remove videos that have no matching .nfo")
+	pruneCmd.Flags().BoolVar(&prunePartial, "partial", false, "Also remove partial downloads")
+	pruneCmd.Flags().BoolVarP(&pruneYes, "yes", "y", false, "Remove without asking")
+	rootCmd.AddCommand(pruneCmd)
+}
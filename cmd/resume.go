@@ -0,0 +1,54 @@
+// cmd/resume.go
+package cmd
+
+import (
+	"fmt"
+
+	"opforjellyfin/internal/logger"
+	"opforjellyfin/internal/shared"
+	"opforjellyfin/internal/torrent"
+	"opforjellyfin/internal/ui"
+
+	"github.com/spf13/cobra"
+)
+
+var resumeCmd = &cobra.Command{
+	Use:   "resume",
+	Short: "Resume interrupted downloads from their partial data",
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, _ := shared.LoadConfig()
+		if cfg.TargetDir == "" {
+			logger.Log(true, "⚠️ No target directory set. Use 'setDir <path>' first.")
+			return
+		}
+
+		entries, err := torrent.PendingResumes()
+		if err != nil {
+			logger.Log(true, "❌ Could not read temp directory: %v", err)
+			return
+		}
+
+		if len(entries) == 0 {
+			fmt.Println("📭 Nothing to resume.")
+			return
+		}
+
+		for _, e := range entries {
+			dKey := ui.StyleFactory(fmt.Sprintf("%4d", e.DownloadKey), ui.Style.Pink)
+			title := ui.StyleFactory(e.TorrentName, ui.Style.LBlue)
+			mode := ""
+			if e.UpgradeOnly {
+				mode = " ⬆️  upgrade only"
+			}
+			logger.Log(true, "♻️  Resuming %s → %s (%s) [%s]%s", dKey, title, e.Quality, e.ChapterRange, mode)
+		}
+
+		applyDownloadFlags(cmd, cfg)
+		torrent.ResumeSession(entries, cfg.TargetDir, torrent.SessionOptions{Seed: seed})
+	},
+}
+
+func init() {
+	addDownloadFlags(resumeCmd)
+	rootCmd.AddCommand(resumeCmd)
+}
@@ -44,12 +44,12 @@ func SetDir(dir string, force bool) {
 	if force {
 		err := metadata.FetchAllMetadata(cfg)
 		if err != nil {
-			fmt.Println("⚠️  Unable to sync metadata. (Is git installed?)")
+			fmt.Println("⚠️  Unable to sync metadata.")
 		}
 	} else {
 		err := metadata.SyncMetadata(cfg)
 		if err != nil {
-			fmt.Println("⚠️  Unable to sync metadata. (Is git installed?)")
+			fmt.Println("⚠️  Unable to sync metadata.")
 		}
 	}
 }
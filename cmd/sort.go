@@ -0,0 +1,65 @@
+// cmd/sort.go
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"opforjellyfin/internal/logger"
+	"opforjellyfin/internal/matcher"
+	"opforjellyfin/internal/metadata"
+	"opforjellyfin/internal/shared"
+	"opforjellyfin/internal/ui"
+
+	"github.com/spf13/cobra"
+)
+
+var sortCmd = &cobra.Command{
+	Use:   "sort <dir>",
+	Short: "Rename and move already downloaded One Pace videos into your library",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, _ := shared.LoadConfig()
+		if cfg.TargetDir == "" {
+			logger.Log(true, "⚠️ No target directory set. Use 'setDir <path>' first.")
+			return
+		}
+
+		srcDir, err := filepath.Abs(args[0])
+		if err != nil {
+			logger.Log(true, "❌ Invalid directory: %v", err)
+			return
+		}
+
+		// the whole library would be walked, including .opfor-tmp
+		if srcDir == filepath.Clean(cfg.TargetDir) {
+			logger.Log(true, "⚠️ Can't sort the target directory itself. Point 'sort' at a folder of loose videos.")
+			return
+		}
+
+		index := metadata.LoadMetadataCache()
+		if len(index.Seasons) == 0 {
+			logger.Log(true, "⚠️ No metadata found. Please run 'sync' first.")
+			return
+		}
+
+		spinner := ui.NewSpinner("🗃️ Sorting.. ", ui.Animations["MoviePlacement"])
+		placed, summary, err := matcher.SortVideoFiles(srcDir, cfg.TargetDir, index)
+		spinner.Stop()
+
+		if err != nil {
+			logger.Log(true, "❌ Could not read %s: %v", srcDir, err)
+			return
+		}
+
+		fmt.Printf("🎞️  %s\n", ui.AnsiPadRight(srcDir, 36, ".."))
+		for _, line := range placed {
+			fmt.Printf("   → %s\n", line)
+		}
+		fmt.Println(summary)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(sortCmd)
+}
@@ -2,28 +2,68 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
 	"opforjellyfin/internal/shared"
+	"opforjellyfin/internal/ui"
+	"time"
 
 	"github.com/spf13/cobra"
 )
 
+var statusJSON bool
+
 var statusCmd = &cobra.Command{
 	Use:   "status",
 	Short: "Show currently active downloads",
 	Run: func(cmd *cobra.Command, args []string) {
-		downloads := shared.GetActiveDownloads()
-		if len(downloads) == 0 {
+		// the session publishes its state to the config dir, so this works
+		// from any terminal - not just the one running 'download'
+		state, err := shared.LoadPublishedDownloads()
+		if err != nil {
+			fmt.Printf("❌ Could not read download state: %v\n", err)
+			return
+		}
+
+		stale := state != nil && time.Since(state.UpdatedAt) > shared.StaleAfter
+
+		if statusJSON {
+			if state == nil || stale {
+				state = &shared.DownloadState{Downloads: []*shared.TorrentDownload{}}
+			}
+			data, _ := json.MarshalIndent(state, "", "  ")
+			fmt.Println(string(data))
+			return
+		}
+
+		if state == nil || len(state.Downloads) == 0 {
 			fmt.Println("📭 No active downloads.")
 			return
 		}
+
+		if stale {
+			fmt.Println("📭 No active downloads.")
+			fmt.Printf("⚠️  Last session (pid %d) stopped without cleaning up at %s. Run 'clear' to remove its leftovers.\n", state.PID, state.UpdatedAt.Format("2006-01-02 15:04:05"))
+			return
+		}
+
 		fmt.Println("📦 Active Downloads:")
-		for _, d := range downloads {
-			fmt.Printf("- %s: %.2f%%\n", d.Title, (float64(d.Progress)/float64(d.TotalSize))*100)
+		for _, d := range state.Downloads {
+			percent := 0.0
+			if d.TotalSize > 0 {
+				percent = (float64(d.Progress) / float64(d.TotalSize)) * 100
+			}
+
+			line := fmt.Sprintf("- %s: %.2f%% of %s", d.Title, percent, ui.FormatBytes(d.TotalSize))
+			if d.PlacementProgress != "" {
+				line += " | " + d.PlacementProgress
+			}
+			fmt.Println(ui.AnsiPadRight(line, ui.GetTerminalWidth()))
 		}
 	},
 }
 
 func init() {
+	statusCmd.Flags().BoolVar(&statusJSON, "json", false, "Print the raw download state as JSON, for scripts")
 	rootCmd.AddCommand(statusCmd)
 }
@@ -5,10 +5,18 @@ import (
 	"fmt"
 	"opforjellyfin/internal/metadata"
 	"opforjellyfin/internal/shared"
+	"opforjellyfin/internal/ui"
+	"strings"
 
 	"github.com/spf13/cobra"
 )
 
+var (
+	syncCheck  bool
+	syncDryRun bool
+	syncApply  bool
+)
+
 var syncCmd = &cobra.Command{
 	Use:   "sync",
 	Short: "Update metadata library with new content from GitHub",
@@ -18,13 +26,129 @@ var syncCmd = &cobra.Command{
 			fmt.Println("⚠️  No target directory set. Use 'setDir' first.")
 			return
 		}
+		switch {
+		case syncCheck:
+			checkUpstream(cfg)
+			return
+		case syncDryRun:
+			previewSync(cfg)
+			return
+		case syncApply:
+			if err := metadata.ApplyPreview(cfg); err != nil {
+				fmt.Printf("⚠️  Unable to apply sync: %v\n", err)
+			}
+			return
+		}
+
 		err := metadata.SyncMetadata(cfg)
 		if err != nil {
-			fmt.Println("⚠️  Unable to sync metadata. (Is git installed?)")
+			fmt.Println("⚠️  Unable to sync metadata.")
 		}
 	},
 }
 
+// reports whether upstream has moved past the installed revision, without
+// touching the library
+func checkUpstream(cfg *shared.Config) {
+	status, err := metadata.CheckUpstream(cfg)
+	if err != nil {
+		fmt.Printf("⚠️  Could not check upstream: %v\n", err)
+		return
+	}
+
+	upstream := metadata.Revision{Ref: status.Ref, Commit: status.Upstream}
+
+	switch {
+	case status.Installed == nil:
+		fmt.Printf("❓ Installed metadata revision unknown, upstream %s is at %s. Run 'opfor sync' to record it.\n", upstream.RefName(), upstream.Short())
+	case status.UpToDate():
+		fmt.Printf("✅ Metadata is up to date (%s @ %s)\n", upstream.RefName(), upstream.Short())
+	default:
+		fmt.Printf("🆕 Upstream has moved: installed %s @ %s, %s is now at %s. Run 'opfor sync' to update.\n",
+			status.Installed.RefName(), status.Installed.Short(), upstream.RefName(), upstream.Short())
+	}
+}
+
+// prints what a sync would change, and remembers it for --apply
+func previewSync(cfg *shared.Config) {
+	plan, err := metadata.PreviewSync(cfg)
+	if err != nil {
+		fmt.Printf("⚠️  Unable to preview sync: %v\n", err)
+		return
+	}
+
+	installed := "unknown"
+	if plan.Base != nil {
+		installed = plan.Base.Short()
+	}
+	fmt.Printf("🔍 Sync preview: %s @ %s (installed: %s)\n", plan.Revision.RefName(), plan.Revision.Short(), installed)
+
+	if plan.Empty() {
+		fmt.Println("✅ Nothing to sync, the library matches upstream.")
+		return
+	}
+
+	printFiles := func(header, mark string, files []string) {
+		if len(files) == 0 {
+			return
+		}
+		fmt.Printf("\n%s (%d):\n", header, len(files))
+		for _, f := range files {
+			fmt.Printf("   %s %s\n", mark, f)
+		}
+	}
+	printFiles("📄 Added", "+", plan.Added)
+	printFiles("📝 Changed", "~", plan.Changed)
+	printFiles("🗑️  No longer upstream (kept)", "-", plan.Removed)
+
+	if len(plan.Renamed) > 0 {
+		fmt.Printf("\n✏️  Renamed episodes (%d):\n", len(plan.Renamed))
+		for _, r := range plan.Renamed {
+			note := ""
+			if r.HasVideo {
+				note = " 🎬 video will be renamed"
+			}
+			from := r.OldSeason + "/" + r.OldTitle
+			to := r.NewTitle
+			if r.NewSeason != r.OldSeason {
+				to = r.NewSeason + "/" + r.NewTitle
+			}
+			fmt.Printf("   [%s] %s → %s%s\n", ui.StyleFactory(r.ChapterRange, ui.Style.Pink), from, ui.StyleFactory(to, ui.Style.LBlue), note)
+		}
+	}
+
+	if len(plan.Seasons) > 0 {
+		fmt.Printf("\n📚 Seasons (%d):\n", len(plan.Seasons))
+		for _, c := range plan.Seasons {
+			fmt.Printf("   %s\n", describeSeasonChange(c))
+		}
+	}
+
+	fmt.Println("\nRun 'opfor sync --apply' to apply exactly this.")
+}
+
+func describeSeasonChange(c metadata.SeasonChange) string {
+	switch {
+	case c.Old == nil:
+		return fmt.Sprintf("+ %s %q %s", c.Key, c.New.Name, c.New.Range)
+	case c.New == nil:
+		return fmt.Sprintf("- %s %q %s", c.Key, c.Old.Name, c.Old.Range)
+	}
+
+	var parts []string
+	if c.Old.Range != c.New.Range {
+		parts = append(parts, fmt.Sprintf("range %s → %s", c.Old.Range, c.New.Range))
+	}
+	if c.Old.Name != c.New.Name {
+		parts = append(parts, fmt.Sprintf("name %q → %q", c.Old.Name, c.New.Name))
+	}
+	return fmt.Sprintf("~ %s: %s", c.Key, strings.Join(parts, ", "))
+}
+
 func init() {
+	syncCmd.Flags().BoolVar(&syncCheck, "check", false, "Only report whether the metadata repo has changed, apply nothing")
+	syncCmd.Flags().BoolVar(&syncDryRun, "dry-run", false, "Show what a sync would change, apply nothing")
+	syncCmd.Flags().BoolVar(&syncApply, "apply", false, "Apply the sync shown by the last --dry-run")
+	syncCmd.MarkFlagsMutuallyExclusive("check", "dry-run", "apply")
 	rootCmd.AddCommand(syncCmd)
 }
@@ -0,0 +1,82 @@
+// cmd/upgrades.go
+package cmd
+
+import (
+	"fmt"
+
+	"opforjellyfin/internal/library"
+	"opforjellyfin/internal/logger"
+	"opforjellyfin/internal/shared"
+	"opforjellyfin/internal/ui"
+
+	"github.com/spf13/cobra"
+)
+
+var upgradesCmd = &cobra.Command{
+	Use:   "upgrades",
+	Short: "List videos in your library that have a better quality release",
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, _ := shared.LoadConfig()
+		if cfg.TargetDir == "" {
+			logger.Log(true, "⚠️ No target directory set. Use 'setDir <path>' first.")
+			return
+		}
+
+		spinner := ui.NewMultirowSpinner(ui.Animations["Searcher"], 4)
+		upgrades, err := findUpgrades(cfg)
+		spinner.Stop()
+		if err != nil {
+			logger.Log(true, "❌ %v", err)
+			return
+		}
+
+		if len(upgrades) == 0 {
+			fmt.Println("✅ Nothing to upgrade.")
+			return
+		}
+
+		fmt.Println("⬆️  Upgrades available:")
+		for _, up := range upgrades {
+			printUpgrade(up)
+		}
+		fmt.Println("Run 'download --upgrade' to get them all, or 'download --upgrade <key>' for some.")
+	},
+}
+
+// better releases for what's in the library, going by the latest search results
+func findUpgrades(cfg *shared.Config) ([]library.Upgrade, error) {
+	lib, err := library.Load()
+	if err != nil {
+		return nil, fmt.Errorf("could not read library: %w", err)
+	}
+
+	if len(lib.Files) == 0 {
+		return nil, nil
+	}
+
+	entries, err := searchResults(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return lib.Upgrades(entries), nil
+}
+
+func printUpgrade(up library.Upgrade) {
+	files := "1 video"
+	if len(up.Files) != 1 {
+		files = fmt.Sprintf("%d videos", len(up.Files))
+	}
+
+	fmt.Printf("   %s  %s [%s] %s → %s (%s)\n",
+		ui.StyleFactory(fmt.Sprintf("%4d", up.Release.DownloadKey), ui.Style.Pink),
+		ui.StyleFactory(up.Release.TorrentName, ui.Style.LBlue),
+		up.ChapterRange,
+		up.Current,
+		ui.StyleByRange(up.Release.Quality, 400, 1000),
+		files)
+}
+
+func init() {
+	rootCmd.AddCommand(upgradesCmd)
+}
@@ -0,0 +1,90 @@
+// cmd/verify.go
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"opforjellyfin/internal/library"
+	"opforjellyfin/internal/logger"
+	"opforjellyfin/internal/shared"
+	"opforjellyfin/internal/ui"
+
+	"github.com/spf13/cobra"
+)
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Re-check every video in your library against its recorded CRC32",
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, _ := shared.LoadConfig()
+		if cfg.TargetDir == "" {
+			logger.Log(true, "⚠️ No target directory set. Use 'setDir <path>' first.")
+			return
+		}
+
+		lib, err := library.Load()
+		if err != nil {
+			logger.Log(true, "❌ Could not read library: %v", err)
+			return
+		}
+
+		if len(lib.Files) == 0 {
+			fmt.Println("📭 Nothing recorded in the library yet.")
+			return
+		}
+
+		paths := make([]string, 0, len(lib.Files))
+		for path := range lib.Files {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+
+		var problems []string
+		ok, unchecked := 0, 0
+
+		spinner := ui.NewSpinner(fmt.Sprintf("🔍 Verifying %d videos.. ", len(paths)), ui.Animations["MetaFetcher"])
+		for _, path := range paths {
+			rec := lib.Files[path]
+			full := filepath.Join(cfg.TargetDir, filepath.FromSlash(path))
+
+			if rec.CRC32 == "" {
+				unchecked++
+				continue
+			}
+
+			got, err := shared.FileCRC32(full)
+			switch {
+			case os.IsNotExist(err):
+				problems = append(problems, fmt.Sprintf("❓ Missing: %s", path))
+			case err != nil:
+				problems = append(problems, fmt.Sprintf("❌ Unreadable: %s (%v)", path, err))
+			case got != rec.CRC32:
+				problems = append(problems, fmt.Sprintf("❌ Corrupt: %s (CRC32 %s, recorded %s)", path, got, rec.CRC32))
+			default:
+				ok++
+			}
+		}
+		spinner.Stop()
+
+		for _, p := range problems {
+			fmt.Println(p)
+		}
+
+		summary := fmt.Sprintf("%d OK, %d with problems", ok, len(problems))
+		if unchecked > 0 {
+			summary += fmt.Sprintf(", %d placed before checksums were recorded", unchecked)
+		}
+		if len(problems) == 0 {
+			fmt.Println("✅ " + summary)
+		} else {
+			fmt.Println("⚠️ " + summary + ". Re-download corrupt or missing ones with 'download'.")
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+}
@@ -0,0 +1,290 @@
+// cmd/watch.go
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"opforjellyfin/internal/flags"
+	"opforjellyfin/internal/logger"
+	"opforjellyfin/internal/metadata"
+	"opforjellyfin/internal/scraper"
+	"opforjellyfin/internal/shared"
+	"opforjellyfin/internal/torrent"
+	"opforjellyfin/internal/watch"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	watchAll      bool
+	watchQuality  = flags.StringChoice([]string{"480p", "720p", "1080p"})
+	watchInterval time.Duration
+	watchQuiet    bool
+	watchOnce     bool
+)
+
+var watchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "Subscribe to arcs or all new releases and download them as they come out",
+}
+
+var watchAddCmd = &cobra.Command{
+	Use:   "add [arc]",
+	Short: "Subscribe to an arc, or to all new releases with --all",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 && !watchAll || len(args) > 0 && watchAll {
+			logger.Log(true, "⚠️ Give an arc name, or --all for every new release")
+			return
+		}
+
+		sub := watch.Subscription{Quality: watchQuality.Value}
+		if len(args) == 1 {
+			if len(metadata.FindSeasonsByName(args[0])) == 0 {
+				logger.Log(true, "❌ No arc named like %q in the metadata. Did you run 'sync'?", args[0])
+				return
+			}
+			sub.Arc = args[0]
+		}
+
+		state, err := watch.Load()
+		if err != nil {
+			logger.Log(true, "❌ Could not read watchlist: %v", err)
+			return
+		}
+
+		if !state.Add(sub) {
+			fmt.Printf("👀 Already watching %s\n", sub)
+			return
+		}
+
+		if err := state.Save(); err != nil {
+			logger.Log(true, "❌ Could not save watchlist: %v", err)
+			return
+		}
+
+		fmt.Printf("👀 Watching %s\n", sub)
+	},
+}
+
+var watchListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Show what you're subscribed to",
+	Run: func(cmd *cobra.Command, args []string) {
+		state, err := watch.Load()
+		if err != nil {
+			logger.Log(true, "❌ Could not read watchlist: %v", err)
+			return
+		}
+
+		if len(state.Subscriptions) == 0 {
+			fmt.Println("📭 Not watching anything. Use 'watch add <arc>' or 'watch add --all'.")
+			return
+		}
+
+		fmt.Println("👀 Watching:")
+		for _, sub := range state.Subscriptions {
+			fmt.Printf("   - %s\n", sub)
+		}
+	},
+}
+
+var watchRemoveCmd = &cobra.Command{
+	Use:   "remove [arc]",
+	Short: "Unsubscribe from an arc, or from all new releases with --all",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 && !watchAll || len(args) > 0 && watchAll {
+			logger.Log(true, "⚠️ Give an arc name, or --all for the all-releases subscription")
+			return
+		}
+
+		arc := ""
+		if len(args) == 1 {
+			arc = args[0]
+		}
+
+		state, err := watch.Load()
+		if err != nil {
+			logger.Log(true, "❌ Could not read watchlist: %v", err)
+			return
+		}
+
+		if state.Remove(arc) == 0 {
+			fmt.Println("🤷 Wasn't watching that.")
+			return
+		}
+
+		if err := state.Save(); err != nil {
+			logger.Log(true, "❌ Could not save watchlist: %v", err)
+			return
+		}
+
+		fmt.Println("🗑️  Removed.")
+	},
+}
+
+var watchRunCmd = &cobra.Command{
+	Use:   "run",
+	Short: "Poll the sources and download new releases of what you watch",
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, _ := shared.LoadConfig()
+		if cfg.TargetDir == "" {
+			logger.Log(true, "⚠️ No target directory set. Use 'setDir <path>' first.")
+			return
+		}
+
+		if len(cfg.AllSources()) == 0 {
+			logger.Log(true, "⚠️ No valid scraper configuration found. Please run 'sync'")
+			return
+		}
+
+		// a seeding session never returns, so there would be no next check
+		if seed {
+			logger.Log(true, "⚠️ --seed can't be used with 'watch run'")
+			return
+		}
+
+		if !watchOnce && watchInterval < time.Minute {
+			logger.Log(true, "⚠️ --interval must be at least 1m - be nice to the trackers")
+			return
+		}
+
+		applyDownloadFlags(cmd, cfg)
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		for {
+			pollWatchlist(cfg)
+
+			if watchOnce {
+				return
+			}
+
+			watchLog(!watchQuiet, "💤 Next check in %s", watchInterval)
+			select {
+			case <-ctx.Done():
+				watchLog(true, "👋 Stopped watching.")
+				return
+			case <-time.After(watchInterval):
+			}
+		}
+	},
+}
+
+// one round of 'watch run': search, and download what's new for the watchlist
+func pollWatchlist(cfg *shared.Config) {
+	state, err := watch.Load()
+	if err != nil {
+		watchLog(true, "❌ Could not read watchlist: %v", err)
+		return
+	}
+
+	if len(state.Subscriptions) == 0 {
+		watchLog(true, "📭 Not watching anything. Use 'watch add <arc>' or 'watch add --all'.")
+		return
+	}
+
+	watchLog(!watchQuiet, "🔎 Checking for new releases..")
+	entries, err := scraper.FetchTorrents(cfg)
+	if err != nil && len(entries) == 0 {
+		watchLog(true, "❌ Error scraping torrents. Site inaccessible? %v", err)
+		return
+	}
+	if err != nil {
+		watchLog(!watchQuiet, "⚠️ Some sources failed, checking the rest: %v", err)
+	}
+
+	state.Refresh(entries)
+	if err == nil {
+		state.Expire()
+	}
+
+	// the very first round only learns what's already out there - otherwise
+	// an all-releases subscription would download the whole catalogue
+	firstRound := len(state.Seen) == 0
+	unseen := state.Unseen(entries)
+
+	if firstRound {
+		state.MarkSeen(unseen...)
+		if err := state.Save(); err != nil {
+			watchLog(true, "❌ Could not save watch state: %v", err)
+			return
+		}
+		watchLog(true, "👀 Noted %d releases already out. New ones will be downloaded from now on.", len(unseen))
+		return
+	}
+
+	queued := make(map[string]bool)
+	var queue []shared.TorrentEntry
+	for _, sub := range state.Subscriptions {
+		for _, e := range sub.Select(unseen) {
+			fp := watch.Fingerprint(e)
+			if queued[fp] {
+				continue
+			}
+			queued[fp] = true
+			watchLog(true, "🆕 %s: %s (%s) [%s]", sub, e.TorrentName, e.Quality, e.ChapterRange)
+			queue = append(queue, e)
+		}
+	}
+
+	// what nothing asked for is seen now, what's queued only once it's
+	// placed - a failed or interrupted download is retried next round
+	for _, e := range unseen {
+		if !queued[watch.Fingerprint(e)] {
+			state.MarkSeen(e)
+		}
+	}
+	if err := state.Save(); err != nil {
+		watchLog(true, "❌ Could not save watch state: %v", err)
+		return
+	}
+
+	if len(queue) == 0 {
+		watchLog(!watchQuiet, "😴 Nothing new.")
+		return
+	}
+
+	placed := torrent.HandleDownloadSession(queue, cfg.TargetDir, torrent.SessionOptions{Quiet: watchQuiet})
+
+	// reloaded, the watchlist may have been edited during the downloads
+	state, err = watch.Load()
+	if err != nil {
+		watchLog(true, "❌ Could not read watchlist: %v", err)
+		return
+	}
+	for i, ok := range placed {
+		if ok {
+			state.MarkSeen(queue[i])
+		}
+	}
+	if err := state.Save(); err != nil {
+		watchLog(true, "❌ Could not save watch state: %v", err)
+	}
+}
+
+// log line with a timestamp, watch runs for a long time
+func watchLog(showUser bool, format string, args ...any) {
+	logger.Log(showUser, "[%s] %s", time.Now().Format("2006-01-02 15:04"), fmt.Sprintf(format, args...))
+}
+
+func init() {
+	watchAddCmd.Flags().BoolVar(&watchAll, "all", false, "Subscribe to every new release")
+	watchAddCmd.Flags().Var(watchQuality, "quality", "Only download this quality (default best available)")
+	watchRemoveCmd.Flags().BoolVar(&watchAll, "all", false, "Remove the all-releases subscription")
+
+	watchRunCmd.Flags().DurationVar(&watchInterval, "interval", 6*time.Hour, "Time between checks, e.g. 30m or 6h")
+	watchRunCmd.Flags().BoolVarP(&watchQuiet, "quiet", "q", false, "Only log new releases, downloads and errors")
+	watchRunCmd.Flags().BoolVar(&watchOnce, "once", false, "Check once and exit, e.g. to run from cron")
+	addDownloadFlags(watchRunCmd)
+
+	watchCmd.AddCommand(watchAddCmd, watchListCmd, watchRemoveCmd, watchRunCmd)
+	rootCmd.AddCommand(watchCmd)
+}
@@ -0,0 +1,261 @@
+// library/library.go
+package library
+
+import (
+	"encoding/json"
+	"opforjellyfin/internal/shared"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const libraryFileName = "library.json"
+
+// Record is what we know about a video placed in the library
+type Record struct {
+	Path         string    `json:"path"`              // relative to the target dir
+	ChapterRange string    `json:"chapter_range"`     // of the torrent it came from
+	Episode      string    `json:"episode,omitempty"` // chapter range of the video itself, if its name has one
+	Quality      string    `json:"quality"`
+	Version      int       `json:"version,omitempty"` // release version, 0 if recorded before versions were
+	Extended     bool      `json:"extended,omitempty"`
+	CRC32        string    `json:"crc32,omitempty"`  // of the file as placed
+	Source       string    `json:"source,omitempty"` // name of the torrent source
+	TorrentTitle string    `json:"torrent_title,omitempty"`
+	PlacedAt     time.Time `json:"placed_at"`
+}
+
+// Library maps placed files (by relative path) to their records
+type Library struct {
+	Files map[string]Record `json:"files"`
+}
+
+// placements run concurrently, each one a load-modify-save of the file
+var mu sync.Mutex
+
+// path to library.json, next to metadata-index.json in the target dir
+func libraryPath() (string, error) {
+	cfg, err := shared.LoadConfig()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cfg.TargetDir, libraryFileName), nil
+}
+
+// Load reads the library, an empty one if nothing has been recorded yet
+func Load() (*Library, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	return load()
+}
+
+func load() (*Library, error) {
+	lib := &Library{Files: make(map[string]Record)}
+
+	path, err := libraryPath()
+	if err != nil {
+		return lib, err
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return lib, nil
+	}
+	if err != nil {
+		return lib, err
+	}
+
+	if err := json.Unmarshal(data, lib); err != nil {
+		return lib, err
+	}
+	if lib.Files == nil {
+		lib.Files = make(map[string]Record)
+	}
+
+	return lib, nil
+}
+
+func (l *Library) save() error {
+	path, err := libraryPath()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return shared.WriteFileAtomic(path, data, 0644)
+}
+
+// Put records a placed file, replacing any earlier record for the same path
+func Put(rec Record) error {
+	return update(func(l *Library) {
+		rec.Path = filepath.ToSlash(rec.Path)
+		if rec.PlacedAt.IsZero() {
+			rec.PlacedAt = time.Now()
+		}
+		l.Files[rec.Path] = rec
+	})
+}
+
+// Remove forgets a file, e.g. one replaced by an upgrade
+func Remove(relPath string) error {
+	return update(func(l *Library) {
+		delete(l.Files, filepath.ToSlash(relPath))
+	})
+}
+
+// Rename moves a file's record to its new path, e.g. after the metadata
+// renamed its episode. Nothing happens for files that aren't recorded
+func Rename(oldRel, newRel string) error {
+	return update(func(l *Library) {
+		rec, ok := l.Files[filepath.ToSlash(oldRel)]
+		if !ok {
+			return
+		}
+		delete(l.Files, rec.Path)
+		rec.Path = filepath.ToSlash(newRel)
+		l.Files[rec.Path] = rec
+	})
+}
+
+// Get returns the record for a file, if there is one
+func (l *Library) Get(relPath string) (Record, bool) {
+	rec, ok := l.Files[filepath.ToSlash(relPath)]
+	return rec, ok
+}
+
+func update(change func(l *Library)) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	lib, err := load()
+	if err != nil {
+		return err
+	}
+
+	change(lib)
+	return lib.save()
+}
+
+// Upgrade is a better release for videos already in the library
+type Upgrade struct {
+	ChapterRange string
+	Current      string   // lowest quality among the placed videos
+	Files        []string // the videos it would replace
+	Release      shared.TorrentEntry
+}
+
+// Upgrades finds, per torrent chapter range in the library, a release in
+// entries of higher quality than what was placed. Videos of unknown quality
+// are never offered an upgrade - we can't tell if it would be one.
+func (l *Library) Upgrades(entries []shared.TorrentEntry) []Upgrade {
+	byRange := make(map[string]*Upgrade)
+	for _, rec := range l.Files {
+		if rec.ChapterRange == "" {
+			continue
+		}
+		up, ok := byRange[rec.ChapterRange]
+		if !ok {
+			up = &Upgrade{ChapterRange: rec.ChapterRange, Current: rec.Quality}
+			byRange[rec.ChapterRange] = up
+		}
+		if shared.QualityValue(rec.Quality) < shared.QualityValue(up.Current) {
+			up.Current = rec.Quality
+		}
+		up.Files = append(up.Files, rec.Path)
+	}
+
+	var upgrades []Upgrade
+	for _, up := range byRange {
+		current := shared.QualityValue(up.Current)
+		if current == 0 {
+			continue
+		}
+
+		found := false
+		for _, e := range entries {
+			if e.ChapterRange != up.ChapterRange || shared.QualityValue(e.Quality) <= current {
+				continue
+			}
+			if !found || shared.QualityValue(e.Quality) > shared.QualityValue(up.Release.Quality) ||
+				(e.Quality == up.Release.Quality && e.Seeders > up.Release.Seeders) {
+				up.Release = e
+				found = true
+			}
+		}
+
+		if found {
+			sort.Strings(up.Files)
+			upgrades = append(upgrades, *up)
+		}
+	}
+
+	sort.Slice(upgrades, func(i, j int) bool {
+		a, _ := shared.ParseRange(upgrades[i].ChapterRange)
+		b, _ := shared.ParseRange(upgrades[j].ChapterRange)
+		return a < b
+	})
+
+	return upgrades
+}
+
+// HasNewerVersion reports whether e is a newer version (v2..) of a video in
+// the library: one placed from the same chapter range, or an episode within
+// it, of the same cut and an older version. A single episode re-uploaded
+// without a new version, but with another CRC32 in its title than the video
+// placed from it, counts too
+func (l *Library) HasNewerVersion(e shared.TorrentEntry) bool {
+	if e.ChapterRange == "" {
+		return false
+	}
+	lo, hi := shared.ParseRange(e.ChapterRange)
+
+	if crc := shared.ExtractCRC32(e.Title); crc != "" {
+		if rec, ok := l.onlyEpisodeOf(e); ok && rec.CRC32 != "" && rec.CRC32 != crc {
+			return true
+		}
+	}
+
+	for _, rec := range l.Files {
+		if rec.Extended != e.Extended {
+			continue
+		}
+		if e.Version <= max(rec.Version, 1) {
+			continue
+		}
+		if rec.ChapterRange == e.ChapterRange {
+			return true
+		}
+		if rec.Episode != "" {
+			epLo, epHi := shared.ParseRange(rec.Episode)
+			if epLo >= lo && epHi <= hi && epLo > 0 {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// onlyEpisodeOf returns the video placed from e's range, quality and cut if
+// it's the release's one episode. A bundle's title CRC32 is of one of its
+// files, which needn't be the one we have
+func (l *Library) onlyEpisodeOf(e shared.TorrentEntry) (Record, bool) {
+	var found []Record
+	for _, rec := range l.Files {
+		if rec.ChapterRange == e.ChapterRange && rec.Quality == e.Quality && rec.Extended == e.Extended {
+			found = append(found, rec)
+		}
+	}
+
+	if len(found) != 1 || (found[0].Episode != "" && found[0].Episode != found[0].ChapterRange) {
+		return Record{}, false
+	}
+	return found[0], true
+}
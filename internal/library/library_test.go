@@ -0,0 +1,71 @@
+package library
+
+import (
+	"testing"
+
+	"opforjellyfin/internal/shared"
+)
+
+func TestUpgrades(t *testing.T) {
+	lib := &Library{Files: map[string]Record{
+		"Season 1/a.mkv": {Path: "Season 1/a.mkv", ChapterRange: "1-7", Quality: "720p"},
+		"Season 1/b.mkv": {Path: "Season 1/b.mkv", ChapterRange: "1-7", Quality: "1080p"},
+		"Season 2/c.mkv": {Path: "Season 2/c.mkv", ChapterRange: "8-11", Quality: "1080p"},
+		"Season 3/d.mkv": {Path: "Season 3/d.mkv", ChapterRange: "12-21", Quality: "n/a"},
+	}}
+
+	entries := []shared.TorrentEntry{
+		{DownloadKey: 1, ChapterRange: "1-7", Quality: "1080p", Seeders: 3},
+		{DownloadKey: 2, ChapterRange: "1-7", Quality: "1080p", Seeders: 9},
+		{DownloadKey: 3, ChapterRange: "8-11", Quality: "1080p", Seeders: 9},
+		{DownloadKey: 4, ChapterRange: "12-21", Quality: "1080p", Seeders: 9},
+	}
+
+	upgrades := lib.Upgrades(entries)
+	if len(upgrades) != 1 {
+		t.Fatalf("got %d upgrades, want 1: %+v", len(upgrades), upgrades)
+	}
+
+	up := upgrades[0]
+	if up.ChapterRange != "1-7" || up.Current != "720p" || up.Release.DownloadKey != 2 || len(up.Files) != 2 {
+		t.Errorf("unexpected upgrade %+v", up)
+	}
+}
+
+func TestHasNewerVersion(t *testing.T) {
+	lib := &Library{Files: map[string]Record{
+		"Season 1/a.mkv":  {ChapterRange: "1-7", Episode: "1-1", Quality: "1080p"},
+		"Season 2/b.mkv":  {ChapterRange: "8-11", Quality: "1080p", Version: 2},
+		"Season 3/c.mkv":  {ChapterRange: "12-12", Episode: "12-12", Quality: "1080p"},
+		"Season 3/cx.mkv": {ChapterRange: "13-13", Quality: "1080p", Extended: true},
+		"Season 4/d.mkv":  {ChapterRange: "31-33", Quality: "1080p", CRC32: "A1B2C3D4"},
+		"Season 5/e1.mkv": {ChapterRange: "34-40", Episode: "34-36", Quality: "1080p", CRC32: "11111111"},
+		"Season 5/e2.mkv": {ChapterRange: "34-40", Episode: "37-40", Quality: "1080p", CRC32: "22222222"},
+		"Season 6/f.mkv":  {ChapterRange: "41-50", Episode: "41-44", Quality: "1080p", CRC32: "33333333"},
+	}}
+
+	tests := []struct {
+		entry shared.TorrentEntry
+		want  bool
+	}{
+		{shared.TorrentEntry{ChapterRange: "1-7", Version: 1}, false},
+		{shared.TorrentEntry{ChapterRange: "1-7", Version: 2}, true},
+		{shared.TorrentEntry{ChapterRange: "8-11", Version: 2}, false},
+		{shared.TorrentEntry{ChapterRange: "12-21", Version: 2}, true},  // bundle with a re-released episode we have
+		{shared.TorrentEntry{ChapterRange: "13-13", Version: 2}, false}, // we have the extended cut
+		{shared.TorrentEntry{ChapterRange: "22-30", Version: 3}, false},
+		// re-uploaded without a new version, told apart by the CRC32
+		{shared.TorrentEntry{Title: "[One Pace][31-33] Loguetown [1080p][A1B2C3D4]", ChapterRange: "31-33", Quality: "1080p", Version: 1}, false},
+		{shared.TorrentEntry{Title: "[One Pace][31-33] Loguetown [1080p][0F1E2D3C]", ChapterRange: "31-33", Quality: "1080p", Version: 1}, true},
+		{shared.TorrentEntry{Title: "[One Pace][31-33] Loguetown [720p][0F1E2D3C]", ChapterRange: "31-33", Quality: "720p", Version: 1}, false},
+		// a bundle's CRC32 is of one of its files, not of each one we have
+		{shared.TorrentEntry{Title: "[One Pace][34-40] Reverse Mountain [1080p][11111111]", ChapterRange: "34-40", Quality: "1080p", Version: 1}, false},
+		{shared.TorrentEntry{Title: "[One Pace][41-50] Whisky Peak [1080p][44444444]", ChapterRange: "41-50", Quality: "1080p", Version: 1}, false},
+	}
+
+	for _, tc := range tests {
+		if got := lib.HasNewerVersion(tc.entry); got != tc.want {
+			t.Errorf("%s v%d: got %v, want %v", tc.entry.ChapterRange, tc.entry.Version, got, tc.want)
+		}
+	}
+}
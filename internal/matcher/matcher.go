@@ -2,7 +2,9 @@
 package matcher
 
 import (
+	"errors"
 	"fmt"
+	"opforjellyfin/internal/library"
 	"opforjellyfin/internal/logger"
 	"opforjellyfin/internal/shared"
 	"opforjellyfin/internal/ui"
@@ -12,9 +14,24 @@ import (
 	"time"
 )
 
+// PlaceOptions describes the video being placed. ChapterRange is the range it
+// is matched under (the torrent's, or the one in the file name for 'sort'),
+// the rest is recorded in the library.
+type PlaceOptions struct {
+	ChapterRange string
+	Quality      string
+	Version      int  // release version of the torrent, overridden by a "v2" in the file name
+	Extended     bool // extended cut
+	Source       string
+	TorrentTitle string
+	UpgradeOnly  bool // only replace an existing video with a better quality one
+	KeepSource   bool // hardlink instead of move, e.g. for the torrent to keep seeding
+}
+
 // Matches video-file to metadata, then places it
-// No mutex needed here - shared.SafeMoveFile handles all locking
-func MatchAndPlaceVideo(videoPath, defaultDir string, index *shared.MetadataIndex, ogcr string) (string, error) {
+// No mutex needed here - shared.PlaceFile never leaves dst half written
+func MatchAndPlaceVideo(videoPath, defaultDir string, index *shared.MetadataIndex, opts PlaceOptions) (string, error) {
+	ogcr := opts.ChapterRange
 
 	if _, err := os.Stat(videoPath); os.IsNotExist(err) {
 		return "", nil
@@ -33,11 +50,28 @@ func MatchAndPlaceVideo(videoPath, defaultDir string, index *shared.MetadataInde
 	ext := filepath.Ext(fileName)
 	finalPath := dstPathNoSuffix + ext
 
+	// copying a file onto itself would truncate it - happens when 'sort' is
+	// pointed at files that are already placed
+	if filepath.Clean(finalPath) == filepath.Clean(videoPath) {
+		logger.Log(false, "%s is already in place", fileName)
+		return fmt.Sprintf("✅ Already in place: %s", ui.AnsiPadRight(fileName, 26, "..")), nil
+	}
+
+	// the episode might already be there, possibly as another container
+	existing := existingVideo(dstPathNoSuffix)
+	if existing != "" && opts.UpgradeOnly && !isUpgrade(defaultDir, existing, fileName, opts) {
+		logger.Log(false, "%s is not better than %s, keeping the existing file", fileName, existing)
+		return fmt.Sprintf("⏭️  Kept existing: %s", ui.AnsiPadRight(filepath.Base(existing), 26, "..")), nil
+	}
+
 	var msg string
 
-	// SafeMoveFile now handles all locking internally
-	if err := shared.SafeMoveFile(videoPath, finalPath); err != nil {
+	// checked against the CRC32 in the file name, if it has one
+	crc, err := shared.MoveFileChecked(videoPath, finalPath, shared.ExtractCRC32(fileName), opts.KeepSource)
+	if err != nil {
 		logger.Log(false, "sfm Error: %s, moving to strayvideos", err)
+		var mismatch *shared.CRCMismatchError
+		corrupt := errors.As(err, &mismatch)
 
 		// Create strayvideos directory using the thread-safe function
 		strayDir := filepath.Join(defaultDir, "strayvideos")
@@ -52,8 +86,7 @@ func MatchAndPlaceVideo(videoPath, defaultDir string, index *shared.MetadataInde
 		strayFileName := fmt.Sprintf("%s_%s%s", nameWithoutExt, timestamp, ext)
 		strayPath := filepath.Join(strayDir, strayFileName)
 
-		// SafeMoveFile handles locking
-		if err := shared.SafeMoveFile(videoPath, strayPath); err != nil {
+		if err := shared.PlaceFile(videoPath, strayPath, opts.KeepSource); err != nil {
 			logger.Log(true, "Failed to move to strayvideos: %v", err)
 			return "", fmt.Errorf("failed to place file anywhere: %w", err)
 		}
@@ -62,6 +95,9 @@ func MatchAndPlaceVideo(videoPath, defaultDir string, index *shared.MetadataInde
 		outFileName := ui.AnsiPadRight(fileName, 26, "..")
 		outRelPath := ui.AnsiPadRight("strayvideos/"+strayFileName, 36, "..")
 		msg = fmt.Sprintf("⚠️  Placed in stray: %s → %s", outFileName, outRelPath)
+		if corrupt {
+			msg = fmt.Sprintf("❌ Corrupt (CRC32 %s, expected %s), placed in stray: %s → %s", mismatch.Got, mismatch.Want, outFileName, outRelPath)
+		}
 
 	} else {
 		//relative path for logs
@@ -81,6 +117,16 @@ func MatchAndPlaceVideo(videoPath, defaultDir string, index *shared.MetadataInde
 		outFileName := ui.AnsiPadRight(fileNameNoPrefix, 26, "..")
 		outRelPath := ui.AnsiPadRight(".."+relPathNoPrefix, 36, "..")
 		msg = fmt.Sprintf("🎞️  Placed: %s → %s", outFileName, outRelPath)
+
+		// an upgrade in another container would leave the old one next to it
+		if existing != "" && opts.UpgradeOnly {
+			msg = fmt.Sprintf("⬆️  Upgraded: %s → %s", outFileName, outRelPath)
+			if existing != finalPath {
+				removeReplaced(defaultDir, existing)
+			}
+		}
+
+		recordPlacement(defaultDir, finalPath, fileName, crc, opts)
 	}
 
 	return msg, nil
@@ -93,7 +139,7 @@ func findMetadataMatch(fileName string, index *shared.MetadataIndex, ogcr string
 	baseDir := cfg.TargetDir
 
 	// strayfolder for unmatched videos
-	strayfolder := filepath.Join(baseDir, "strayvideos", ogcr, fileName)
+	strayfolder := filepath.Join(baseDir, "strayvideos", ogcr, strings.TrimSuffix(fileName, filepath.Ext(fileName)))
 	// finds season containing chapterRange, returns the seasonFolderName and seasonIndex
 	// uses ogcr to find correct season even if its a bundle
 	seasonFolderName, seasonIndex := findSeasonForChapter(ogcr, index)
@@ -191,3 +237,87 @@ func findTitleRough(epKey string, sindex shared.SeasonIndex) string {
 	logger.Log(false, "roughFindTitle did not find a match. for %s", epKey)
 	return ""
 }
+
+// returns the video already placed at pathNoSuffix, "" if there is none
+func existingVideo(pathNoSuffix string) string {
+	for _, ext := range []string{".mkv", ".mp4"} {
+		if shared.FileExists(pathNoSuffix + ext) {
+			return pathNoSuffix + ext
+		}
+	}
+	return ""
+}
+
+// whether the video beats the recorded quality (or, at the same quality, the
+// version) of the existing one. Videos the library knows nothing about, or not
+// the quality of, are left alone
+func isUpgrade(baseDir, existing, fileName string, opts PlaceOptions) bool {
+	lib, err := library.Load()
+	if err != nil {
+		logger.Log(false, "isUpgrade: could not load library: %v", err)
+		return false
+	}
+
+	rel, _ := filepath.Rel(baseDir, existing)
+	rec, ok := lib.Get(rel)
+	if !ok {
+		return false
+	}
+
+	current, quality := shared.QualityValue(rec.Quality), shared.QualityValue(opts.Quality)
+	if current == 0 || quality < current {
+		return false
+	}
+
+	version, _ := videoVersion(fileName, opts)
+	return quality > current || version > max(rec.Version, 1)
+}
+
+// removes a video replaced by an upgrade, and its library record
+func removeReplaced(baseDir, path string) {
+	if err := os.Remove(path); err != nil {
+		logger.Log(true, "Failed to remove replaced video %s: %v", path, err)
+		return
+	}
+
+	rel, _ := filepath.Rel(baseDir, path)
+	if err := library.Remove(rel); err != nil {
+		logger.Log(false, "Failed to update library: %v", err)
+	}
+}
+
+// records a placed video in the library. Strays aren't part of it
+func recordPlacement(baseDir, path, fileName, crc string, opts PlaceOptions) {
+	rel, err := filepath.Rel(baseDir, path)
+	if err != nil || strings.HasPrefix(filepath.ToSlash(rel), "strayvideos/") {
+		return
+	}
+
+	version, extended := videoVersion(fileName, opts)
+
+	err = library.Put(library.Record{
+		Path:         rel,
+		ChapterRange: opts.ChapterRange,
+		Episode:      chapterRangeFromFileName(fileName),
+		Quality:      opts.Quality,
+		Version:      version,
+		Extended:     extended,
+		CRC32:        crc,
+		Source:       opts.Source,
+		TorrentTitle: opts.TorrentTitle,
+	})
+	if err != nil {
+		logger.Log(false, "Failed to record %s in library: %v", rel, err)
+	}
+}
+
+// release version of a video: from its own name if it has a marker (a single
+// re-released episode in a bundle says so there), else the torrent's
+func videoVersion(fileName string, opts PlaceOptions) (int, bool) {
+	version, extended := shared.ExtractReleaseVersion(fileName)
+	if version == 0 {
+		version = max(opts.Version, 1)
+	}
+
+	return version, extended || opts.Extended
+}
@@ -15,23 +15,15 @@ func ProcessTorrentFiles(tmpDir, outDir string, td *shared.TorrentDownload, inde
 	filesPlaced := 0
 	var lastError error
 
+	// hardlinked files can keep seeding from tmpDir
+	cfg, _ := shared.LoadConfig()
+	keepSource := cfg != nil && cfg.Placement == shared.PlacementHardlink
+
 	// collect all paths
 	td.PlacementProgress = fmt.Sprintf("🔧 Finding files to place %s", tmpDir)
+	shared.SaveTorrentDownload(td)
 
-	var vidPaths []string
-	err := filepath.Walk(tmpDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			logger.Log(true, "Failed walking file: %v", err)
-			return nil
-		}
-		if info.IsDir() || (!strings.HasSuffix(strings.ToLower(info.Name()), ".mkv") && !strings.HasSuffix(strings.ToLower(info.Name()), ".mp4")) {
-			return nil
-		}
-		logger.Log(false, "added path: %s", path)
-		vidPaths = append(vidPaths, path)
-		return nil
-	})
-
+	vidPaths, err := collectVideoFiles(tmpDir)
 	if err != nil {
 		logger.Log(true, "Error walking tmpDir: %v", err)
 		return
@@ -56,9 +48,19 @@ func ProcessTorrentFiles(tmpDir, outDir string, td *shared.TorrentDownload, inde
 
 		// upd msg
 		td.PlacementProgress = fmt.Sprintf("🔧 Placing ➝ %d/%d - %s", (filesPlaced + 1), len(vidPaths), readablePath)
+		shared.SaveTorrentDownload(td)
 
 		// match and place
-		msg, err := MatchAndPlaceVideo(path, outDir, index, td.ChapterRange)
+		msg, err := MatchAndPlaceVideo(path, outDir, index, PlaceOptions{
+			ChapterRange: td.ChapterRange,
+			Quality:      td.Quality,
+			Version:      td.Version,
+			Extended:     td.Extended,
+			Source:       td.Source,
+			TorrentTitle: td.FullTitle,
+			UpgradeOnly:  td.UpgradeOnly,
+			KeepSource:   keepSource,
+		})
 		if err != nil {
 			logger.Log(true, "Error placing file: %v", err)
 			lastError = err
@@ -70,24 +72,93 @@ func ProcessTorrentFiles(tmpDir, outDir string, td *shared.TorrentDownload, inde
 		}
 	}
 
-	// Create appropriate message based on results
-	var placedMsg string
+	td.MarkPlaced(placementSummary(filesPlaced, len(vidPaths), lastError))
+	logger.Log(false, "File placement done: %d checked, %d placed", filesChecked, filesPlaced)
+}
+
+// SortVideoFiles places loose video files from srcDir (e.g. episodes downloaded
+// outside of opfor) into outDir. Unlike ProcessTorrentFiles there is no torrent
+// chapter range to go by, so each file is matched on the range in its own name.
+// Returns the per-file placement messages and a summary line.
+func SortVideoFiles(srcDir, outDir string, index *shared.MetadataIndex) ([]string, string, error) {
+	vidPaths, err := collectVideoFiles(srcDir)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if len(vidPaths) == 0 {
+		return nil, "⚠️ No video files found to place!", nil
+	}
 
+	var placed []string
+	var lastError error
+
+	for _, path := range vidPaths {
+		fileName := filepath.Base(path)
+		chapterRange := chapterRangeFromFileName(fileName)
+		logger.Log(false, "sort: %s → chapter range %q", fileName, chapterRange)
+
+		msg, err := MatchAndPlaceVideo(path, outDir, index, PlaceOptions{
+			ChapterRange: chapterRange,
+			Quality:      shared.ParseQuality(fileName),
+		})
+		if err != nil {
+			logger.Log(false, "sort: error placing %s: %v", fileName, err)
+			lastError = err
+		} else if msg != "" {
+			placed = append(placed, msg)
+		}
+	}
+
+	return placed, placementSummary(len(placed), len(vidPaths), lastError), nil
+}
+
+// collectVideoFiles returns the paths of all .mkv/.mp4 files below dir
+func collectVideoFiles(dir string) ([]string, error) {
+	var vidPaths []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			logger.Log(true, "Failed walking file: %v", err)
+			return nil
+		}
+		if info.IsDir() || (!strings.HasSuffix(strings.ToLower(info.Name()), ".mkv") && !strings.HasSuffix(strings.ToLower(info.Name()), ".mp4")) {
+			return nil
+		}
+		logger.Log(false, "added path: %s", path)
+		vidPaths = append(vidPaths, path)
+		return nil
+	})
+
+	return vidPaths, err
+}
+
+// chapterRangeFromFileName tries the strict "[One Pace][x-y]" form first, then
+// falls back to a rough "Chapter x-y" match. Returns "" if neither gives a range.
+func chapterRangeFromFileName(fileName string) string {
+	if cr := shared.ExtractChapterRangeFromTitle(fileName); cr != "" {
+		return cr
+	}
+
+	if cr, isRange := shared.RoughExtractChapterFromTitle(fileName); isRange {
+		return cr
+	}
+
+	return ""
+}
+
+// placementSummary creates the appropriate message based on placement results
+func placementSummary(filesPlaced, total int, lastError error) string {
 	if filesPlaced == 0 && lastError != nil {
-		placedMsg = fmt.Sprintf("❌ Failed to place any files! Last error: %v", lastError)
+		return fmt.Sprintf("❌ Failed to place any files! Last error: %v", lastError)
 	} else if filesPlaced == 0 {
-		placedMsg = "❌ No files could be placed!"
-	} else if filesPlaced == len(vidPaths) {
+		return "❌ No files could be placed!"
+	} else if filesPlaced == total {
 		if filesPlaced == 1 {
-			placedMsg = "✅ 1 file placed!"
-		} else {
-			placedMsg = fmt.Sprintf("✅ All %d files placed!", filesPlaced)
+			return "✅ 1 file placed!"
 		}
-	} else {
-		// Partial success
-		placedMsg = fmt.Sprintf("⚠️ %d/%d files placed!", filesPlaced, len(vidPaths))
+		return fmt.Sprintf("✅ All %d files placed!", filesPlaced)
 	}
 
-	td.MarkPlaced(placedMsg)
-	logger.Log(false, "File placement done: %d checked, %d placed", filesChecked, filesPlaced)
+	// Partial success
+	return fmt.Sprintf("⚠️ %d/%d files placed!", filesPlaced, total)
 }
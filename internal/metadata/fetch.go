@@ -0,0 +1,202 @@
+// metadata/fetch.go
+package metadata
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"opforjellyfin/internal/logger"
+	"opforjellyfin/internal/shared"
+)
+
+const (
+	FetcherArchive = "archive"
+	FetcherGit     = "git"
+)
+
+// where repository tarballs are downloaded from, a var so tests can point it
+// at a local server
+var githubAPI = "https://api.github.com"
+
+// the whole repo archive incl. images, so much longer than a scraper request
+const archiveTimeout = 10 * time.Minute
+
+// the parts of the metadata repo opfor uses, everything else in the archive is
+// skipped
+const metadataSubtree = "One Pace"
+
+// fetchRepo puts the metadata repo at ref (branch, tag or commit, empty for
+// the default branch) into dir, which must not exist yet, and returns the
+// commit it got. Uses the archive download unless cfg asks for git.
+func fetchRepo(cfg *shared.Config, ref, dir string) (string, error) {
+	if cfg.MetadataFetcher == FetcherGit {
+		if _, err := exec.LookPath("git"); err == nil {
+			return gitFetch(cfg.GitHubRepo, ref, dir)
+		}
+		logger.Log(true, "⚠️  git not found, downloading the metadata archive instead")
+	}
+
+	// resolved first, so the archive and the recorded revision can't differ
+	// if upstream moves in between
+	commit, err := resolveRevision(cfg.GitHubRepo, ref)
+	if err != nil {
+		return "", err
+	}
+
+	return commit, downloadArchive(cfg.GitHubRepo, commit, dir)
+}
+
+// shallow fetches repo at ref into dir. A plain clone can't check out a
+// commit, so a pinned ref is fetched into an empty repo instead
+func gitFetch(repo, ref, dir string) (string, error) {
+	url := fmt.Sprintf("https://github.com/%s.git", repo)
+	logger.Log(false, "metadata: git fetch %s at %s", url, refName(ref))
+
+	steps := [][]string{{"clone", "--depth=1", url, dir}}
+	if ref != "" {
+		steps = [][]string{
+			{"init", "--quiet", dir},
+			{"-C", dir, "fetch", "--depth=1", url, ref},
+			{"-C", dir, "checkout", "--quiet", "FETCH_HEAD"},
+		}
+	}
+
+	for _, args := range steps {
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			return "", fmt.Errorf("git %s failed: %w: %s", args[0], err, strings.TrimSpace(string(out)))
+		}
+	}
+
+	out, err := exec.Command("git", "-C", dir, "rev-parse", "HEAD").Output()
+	if err != nil {
+		return "", fmt.Errorf("git rev-parse failed: %w", err)
+	}
+
+	return strings.TrimSpace(string(out)), nil
+}
+
+// downloadArchive fetches the tarball of repo at commit and extracts the
+// metadata subtree and config.json into dir
+func downloadArchive(repo, commit, dir string) error {
+	url := fmt.Sprintf("%s/repos/%s/tarball/%s", githubAPI, repo, commit)
+	logger.Log(false, "metadata: downloading %s", url)
+
+	ctx, cancel := context.WithTimeout(context.Background(), archiveTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("archive download failed: %s", resp.Status)
+	}
+
+	return extractArchive(resp.Body, dir)
+}
+
+// extractArchive unpacks a gzipped repo tarball into dir. GitHub wraps
+// everything in one "<owner>-<repo>-<sha>/" folder, which is stripped.
+func extractArchive(r io.Reader, dir string) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("archive is not gzipped: %w", err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("corrupt archive: %w", err)
+		}
+
+		rel, ok := archivePath(hdr.Name)
+		if !ok {
+			continue
+		}
+		dst := filepath.Join(dir, filepath.FromSlash(rel))
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(dst, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := writeArchiveFile(tr, dst); err != nil {
+				return err
+			}
+		default:
+			// links and the like have no business in the metadata
+			logger.Log(false, "metadata: skipping %s (type %c)", hdr.Name, hdr.Typeflag)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, metadataSubtree)); err != nil {
+		return fmt.Errorf("archive has no %q folder", metadataSubtree)
+	}
+
+	return nil
+}
+
+// archivePath maps a tarball entry to its path inside the repo, and reports
+// whether it should be extracted. Entries escaping the repo are refused.
+func archivePath(name string) (string, bool) {
+	// drop the wrapping folder
+	_, rel, found := strings.Cut(name, "/")
+	if !found || rel == "" {
+		return "", false
+	}
+
+	rel = path.Clean(rel)
+	if !filepath.IsLocal(filepath.FromSlash(rel)) {
+		logger.Log(true, "⚠️  refusing archive entry outside the repo: %s", name)
+		return "", false
+	}
+
+	if rel == "config.json" || rel == metadataSubtree || strings.HasPrefix(rel, metadataSubtree+"/") {
+		return rel, true
+	}
+
+	return "", false
+}
+
+func writeArchiveFile(r io.Reader, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, r); err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
@@ -0,0 +1,140 @@
+package metadata
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"opforjellyfin/internal/shared"
+)
+
+const testCommit = "0123456789abcdef0123456789abcdef01234567"
+
+// builds a gzipped tarball laid out like GitHub's, files maps path -> content
+func fixtureArchive(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+
+	for name, content := range files {
+		hdr := &tar.Header{
+			Name:     "tissla-one-pace-jellyfin-abc1234/" + name,
+			Mode:     0644,
+			Size:     int64(len(content)),
+			Typeflag: tar.TypeReg,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+// points githubAPI at a server that has files as the repo, at testCommit
+func serveRepo(t *testing.T, files map[string]string) {
+	t.Helper()
+
+	archive := fixtureArchive(t, files)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "/commits/") {
+			w.Write([]byte(testCommit))
+			return
+		}
+		w.Write(archive)
+	}))
+	t.Cleanup(srv.Close)
+
+	old := githubAPI
+	githubAPI = srv.URL
+	t.Cleanup(func() { githubAPI = old })
+}
+
+func TestFetchRepoArchive(t *testing.T) {
+	archive := fixtureArchive(t, map[string]string{
+		"One Pace/Season 01/S01E01.nfo": "<episodedetails/>",
+		"config.json":                   `{"name":"test"}`,
+		"README.md":                     "not wanted",
+		"../escape.txt":                 "evil",
+	})
+
+	var gotPath string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/repos/tissla/one-pace-jellyfin/commits/HEAD" {
+			w.Write([]byte(testCommit))
+			return
+		}
+		gotPath = r.URL.Path
+		w.Write(archive)
+	}))
+	defer srv.Close()
+
+	old := githubAPI
+	githubAPI = srv.URL
+	defer func() { githubAPI = old }()
+
+	base := t.TempDir()
+	dir := filepath.Join(base, "repo-tmp")
+	cfg := &shared.Config{GitHubRepo: "tissla/one-pace-jellyfin"}
+
+	commit, err := fetchRepo(cfg, "", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the archive must be of the resolved commit, not whatever HEAD is by then
+	if commit != testCommit || gotPath != "/repos/tissla/one-pace-jellyfin/tarball/"+testCommit {
+		t.Errorf("got commit %s from %s", commit, gotPath)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "One Pace", "Season 01", "S01E01.nfo")); string(data) != "<episodedetails/>" {
+		t.Errorf("nfo not extracted, got %q", data)
+	}
+	if !shared.FileExists(filepath.Join(dir, "config.json")) {
+		t.Error("config.json not extracted")
+	}
+	if shared.FileExists(filepath.Join(dir, "README.md")) {
+		t.Error("files outside the metadata subtree were extracted")
+	}
+	if shared.FileExists(filepath.Join(base, "escape.txt")) {
+		t.Error("archive entry escaped the target dir")
+	}
+}
+
+func TestFetchRepoArchiveErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/repos/owner/missing/commits/HEAD" {
+			http.NotFound(w, r)
+			return
+		}
+		if strings.Contains(r.URL.Path, "/commits/") {
+			w.Write([]byte(testCommit))
+			return
+		}
+		w.Write(fixtureArchive(t, map[string]string{"README.md": "no metadata here"}))
+	}))
+	defer srv.Close()
+
+	old := githubAPI
+	githubAPI = srv.URL
+	defer func() { githubAPI = old }()
+
+	for _, repo := range []string{"owner/missing", "owner/empty"} {
+		cfg := &shared.Config{GitHubRepo: repo}
+		if _, err := fetchRepo(cfg, "", filepath.Join(t.TempDir(), "repo-tmp")); err == nil {
+			t.Errorf("%s: expected an error", repo)
+		}
+	}
+}
@@ -8,7 +8,6 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
-	"strconv"
 	"strings"
 )
 
@@ -33,10 +32,7 @@ func buildIndexFromDir(baseDir string) (*shared.MetadataIndex, error) {
 			return nil
 		}
 
-		seasonKey := fmt.Sprintf("Season %s", season)
-		if season == "00" || season == "0" {
-			seasonKey = "Specials"
-		}
+		seasonKey := shared.SeasonKey(season)
 
 		// chapterRange used by index
 		normalized := shared.NormalizeDash(chapterRange)
@@ -113,9 +109,8 @@ func nameSeasons(index *shared.MetadataIndex, baseDir string) {
 			seasonNum := string(match[1])
 			seasonName := string(match[2])
 
-			// Create the season key (e.g., "Season 01", "Season 10")
-			snum, _ := strconv.Atoi(seasonNum)
-			seasonKey := fmt.Sprintf("Season %d", snum)
+			// Create the season key (e.g., "Season 1", "Season 10")
+			seasonKey := shared.SeasonKey(seasonNum)
 
 			// Remove the number prefix from the name (e.g., "1. Romance Dawn" -> "Romance Dawn")
 			cleanName := strings.TrimSpace(seasonName)
@@ -0,0 +1,48 @@
+package metadata
+
+import (
+	"opforjellyfin/internal/shared"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FindSeasonsByName returns the seasons whose name contains name (case
+// insensitive), in chapter order. Specials are never matched.
+func FindSeasonsByName(name string) []shared.SeasonIndex {
+	index := LoadMetadataCache()
+	name = strings.ToLower(strings.TrimSpace(name))
+
+	var seasons []shared.SeasonIndex
+	for key, season := range index.Seasons {
+		if key == "Specials" || season.Name == "" {
+			continue
+		}
+		if strings.Contains(strings.ToLower(season.Name), name) {
+			seasons = append(seasons, season)
+		}
+	}
+
+	sort.Slice(seasons, func(i, j int) bool {
+		a, _ := shared.ParseRange(seasons[i].Range)
+		b, _ := shared.ParseRange(seasons[j].Range)
+		return a < b
+	})
+
+	return seasons
+}
+
+// FindSeasonByNumber returns season n, as numbered in the library. Goes by
+// shared.SeasonNumber, so an index keyed "Season 01" still has season 1
+func FindSeasonByNumber(n int) (shared.SeasonIndex, bool) {
+	index := LoadMetadataCache()
+	if season, ok := index.Seasons[shared.SeasonKey(strconv.Itoa(n))]; ok {
+		return season, true
+	}
+	for key, season := range index.Seasons {
+		if n > 0 && shared.SeasonNumber(key) == n {
+			return season, true
+		}
+	}
+	return shared.SeasonIndex{}, false
+}
@@ -5,8 +5,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"time"
 
 	"opforjellyfin/internal/logger"
 	"opforjellyfin/internal/shared"
@@ -23,41 +23,70 @@ func BuildMetadataIndex(baseDir string) error {
 	return saveMetadataIndex(index, baseDir)
 }
 
-// FetchAllMetadata clones and indexes metadata from GitHub.
+// FetchAllMetadata downloads and indexes metadata from GitHub.
 func FetchAllMetadata(cfg *shared.Config) error {
-	return cloneAndCopyRepo(cfg, false)
+	return cloneAndCopyRepo(cfg, false, cfg.MetadataRef)
 }
 
-// SyncMetadata clones and syncs metadata updates from GitHub.
+// SyncMetadata downloads and syncs metadata updates from GitHub.
 func SyncMetadata(cfg *shared.Config) error {
-	return cloneAndCopyRepo(cfg, true)
+	return cloneAndCopyRepo(cfg, true, cfg.MetadataRef)
 }
 
-// Main dataobtainer, builds or rebuilds index when complete.
-func cloneAndCopyRepo(cfg *shared.Config, syncOnly bool) error {
-
-	baseDir := cfg.TargetDir
-
+// stageRepo downloads the metadata repo at ref into a temp dir. tmpDir stays
+// locked, so prune leaves it alone, until the caller's cleanup removes it
+func stageRepo(cfg *shared.Config, ref string) (tmpDir, commit string, cleanup func(), err error) {
 	tmpBase, err := shared.GetTempDir()
 	if err != nil {
-		return err
+		return "", "", nil, err
 	}
-	tmpDir := filepath.Join(tmpBase, "repo-tmp")
-	defer os.RemoveAll(tmpDir)
+	tmpDir = filepath.Join(tmpBase, "repo-tmp")
 
-	repo := fmt.Sprintf("https://github.com/%s.git", cfg.GitHubRepo)
+	unlock := shared.LockPath(tmpDir)
+	cleanup = func() {
+		os.RemoveAll(tmpDir)
+		unlock()
+	}
+	// a leftover from an interrupted run would make git refuse to clone and
+	// mix stale files into the archive extract
+	os.RemoveAll(tmpDir)
 
-	fmt.Printf("%s", "🌐 Fetching metadata from "+repo+"\n")
+	fmt.Printf("%s", "🌐 Fetching metadata from https://github.com/"+cfg.GitHubRepo+"\n")
 
 	spinner := ui.NewSpinner("🗃️ Downloading.. ", ui.Animations["MetaFetcher"])
+	commit, err = fetchRepo(cfg, ref, tmpDir)
+	spinner.Stop()
 
-	if err = exec.Command("git", "clone", "--depth=1", repo, tmpDir).Run(); err != nil {
-		spinner.Stop()
-		fmt.Println("⚠️  Git clone failed: %w", err)
+	if err != nil {
+		cleanup()
+		fmt.Printf("⚠️  Metadata download failed: %v\n", err)
+		return "", "", nil, err
+	}
+
+	return tmpDir, commit, cleanup, nil
+}
+
+// Main dataobtainer, builds or rebuilds index when complete. ref overrides
+// cfg.MetadataRef, e.g. to apply exactly a previewed commit
+func cloneAndCopyRepo(cfg *shared.Config, syncOnly bool, ref string) error {
+
+	baseDir := cfg.TargetDir
+
+	tmpDir, commit, cleanup, err := stageRepo(cfg, ref)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	srcDir := filepath.Join(tmpDir, metadataSubtree)
+
+	// the renames are followed once the new index is in
+	_, newIndex, renames, err := syncIndexes(srcDir, baseDir)
+	if err != nil {
 		return err
 	}
 
-	srcDir := filepath.Join(tmpDir, "One Pace")
+	spinner := ui.NewSpinner("🗃️ Copying.. ", ui.Animations["MetaFetcher"])
 
 	if syncOnly {
 		err = shared.SyncDir(srcDir, baseDir)
@@ -71,20 +100,22 @@ func cloneAndCopyRepo(cfg *shared.Config, syncOnly bool) error {
 	}
 
 	// If the metadata repo ships its own pre-built metadata-index.json inside
-	// "One Pace", CopyDir/SyncDir already copied it into baseDir above - use it
-	// directly instead of re-deriving it from every .nfo file via regex on this
-	// machine. Falls back to building it locally for repos that don't ship one.
+	// "One Pace", CopyDir/SyncDir already copied it into baseDir above and
+	// stagedIndex read it as-is. Otherwise the one built from the staged
+	// .nfo files is saved.
 	if shared.FileExists(filepath.Join(srcDir, "metadata-index.json")) {
 		logger.Log(false, "metadata: using prebuilt metadata-index.json shipped by the repo")
-		if err := loadIndexIntoCache(baseDir); err != nil {
-			spinner.Stop()
-			return err
-		}
-	} else if err := BuildMetadataIndex(baseDir); err != nil {
+		metadataCache = newIndex
+	} else if err := saveMetadataIndex(newIndex, baseDir); err != nil {
 		spinner.Stop()
 		return err
 	}
 
+	rev := Revision{Repo: cfg.GitHubRepo, Ref: cfg.MetadataRef, Commit: commit, SyncedAt: time.Now()}
+	if err := saveRevision(baseDir, rev); err != nil {
+		logger.Log(true, "⚠️  Could not record metadata revision: %v", err)
+	}
+
 	if err := loadSourceConfig(tmpDir, cfg); err != nil {
 		logger.Log(false, "metadata: could not load scraper config from repo: %v", err)
 	}
@@ -96,14 +127,24 @@ func cloneAndCopyRepo(cfg *shared.Config, syncOnly bool) error {
 
 	spinner.Stop()
 
+	if len(renames) > 0 {
+		n := applyRenames(baseDir, renames)
+		fmt.Printf("✏️  %d of %d renamed episodes followed\n", n, len(renames))
+	}
+
+	// after the renames, which clear up the .nfo files they replaced
+	if syncOnly {
+		shared.WarnStaleMetadataFiles(srcDir, baseDir)
+	}
+
 	path := filepath.Join(baseDir, "metadata-index.json")
 	fmt.Println("\n✅ Saved metadata index to", path)
 
-	fmt.Println("✅ Metadata fetch and indexing complete.")
+	fmt.Printf("✅ Metadata fetch and indexing complete (%s @ %s).\n", rev.RefName(), rev.Short())
 	return nil
 }
 
-// loadSourceConfig reads config.json from the freshly cloned metadata repo and
+// loadSourceConfig reads config.json from the freshly fetched metadata repo and
 // populates cfg.Source (the scraper's site config) with it, so switching trackers
 // is a config change in the metadata repo rather than a code change here.
 func loadSourceConfig(tmpDir string, cfg *shared.Config) error {
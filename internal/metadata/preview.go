@@ -0,0 +1,369 @@
+// metadata/preview.go
+package metadata
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"opforjellyfin/internal/logger"
+	"opforjellyfin/internal/shared"
+)
+
+// SyncPlan is what a sync would change in the target dir, worked out against a
+// staged copy of the metadata repo before anything is written
+type SyncPlan struct {
+	Revision Revision  // what would be installed
+	Base     *Revision // what is installed now, nil if unknown
+
+	Added   []string // paths relative to the target dir, slash separated
+	Changed []string
+	Removed []string // gone upstream; sync keeps them, see shared.WarnStaleMetadataFiles
+
+	Renamed []EpisodeRename
+	Seasons []SeasonChange
+}
+
+// EpisodeRename is a chapter range that upstream now files under another
+// title or season
+type EpisodeRename struct {
+	ChapterRange string
+	OldSeason    string
+	OldTitle     string
+	NewSeason    string
+	NewTitle     string
+	HasVideo     bool // a video is placed under the old title
+}
+
+// SeasonChange is a season added, removed, or with a new range or name
+type SeasonChange struct {
+	Key      string
+	Old, New *shared.SeasonIndex // nil when added / removed
+}
+
+// Empty reports whether the sync would change nothing
+func (p *SyncPlan) Empty() bool {
+	return len(p.Added)+len(p.Changed)+len(p.Removed)+len(p.Renamed)+len(p.Seasons) == 0
+}
+
+// metadata files the preview reports on - videos and our own state files
+// are not the metadata repo's business
+var previewExts = map[string]bool{".nfo": true, ".png": true, ".jpg": true, ".jpeg": true, ".webp": true}
+
+// pendingSync is the last previewed sync, so --apply installs exactly the
+// commit that was shown
+type pendingSync struct {
+	Repo       string    `json:"repo"`
+	Commit     string    `json:"commit"`
+	BaseCommit string    `json:"base_commit,omitempty"`
+	TargetDir  string    `json:"target_dir"`
+	PreviewAt  time.Time `json:"previewed_at"`
+}
+
+func pendingSyncPath() string {
+	return filepath.Join(shared.ConfigDir(), "sync-preview.json")
+}
+
+// PreviewSync downloads the metadata repo and works out what syncing it would
+// change, without touching the target dir. The previewed commit is remembered
+// for ApplyPreview
+func PreviewSync(cfg *shared.Config) (*SyncPlan, error) {
+	base, err := LoadRevision(cfg.TargetDir)
+	if err != nil {
+		return nil, err
+	}
+
+	tmpDir, commit, cleanup, err := stageRepo(cfg, cfg.MetadataRef)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	plan, err := diffSync(filepath.Join(tmpDir, metadataSubtree), cfg.TargetDir)
+	if err != nil {
+		return nil, err
+	}
+	plan.Revision = Revision{Repo: cfg.GitHubRepo, Ref: cfg.MetadataRef, Commit: commit}
+	plan.Base = base
+
+	pending := pendingSync{Repo: cfg.GitHubRepo, Commit: commit, TargetDir: cfg.TargetDir, PreviewAt: time.Now()}
+	if base != nil {
+		pending.BaseCommit = base.Commit
+	}
+
+	data, err := json.MarshalIndent(pending, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := shared.WriteFileAtomic(pendingSyncPath(), data, 0644); err != nil {
+		return nil, fmt.Errorf("could not save sync preview: %w", err)
+	}
+
+	return plan, nil
+}
+
+// ErrNoPreview is returned by ApplyPreview when there's nothing previewed
+var ErrNoPreview = errors.New("no sync preview, run 'opfor sync --dry-run' first")
+
+// ApplyPreview syncs the commit shown by the last PreviewSync. Refuses if the
+// preview no longer describes what would happen, i.e. the target dir, repo
+// or installed revision changed since
+func ApplyPreview(cfg *shared.Config) error {
+	data, err := os.ReadFile(pendingSyncPath())
+	if os.IsNotExist(err) {
+		return ErrNoPreview
+	}
+	if err != nil {
+		return err
+	}
+
+	var pending pendingSync
+	if err := json.Unmarshal(data, &pending); err != nil {
+		return fmt.Errorf("could not parse sync preview: %w", err)
+	}
+
+	base, err := LoadRevision(cfg.TargetDir)
+	if err != nil {
+		return err
+	}
+	baseCommit := ""
+	if base != nil {
+		baseCommit = base.Commit
+	}
+
+	if pending.Repo != cfg.GitHubRepo || pending.TargetDir != cfg.TargetDir || pending.BaseCommit != baseCommit {
+		return errors.New("the library or metadata source changed since the preview, run 'opfor sync --dry-run' again")
+	}
+
+	if err := cloneAndCopyRepo(cfg, true, pending.Commit); err != nil {
+		return err
+	}
+
+	return os.Remove(pendingSyncPath())
+}
+
+// diffSync compares the staged metadata in srcDir with the target dir
+func diffSync(srcDir, dstDir string) (*SyncPlan, error) {
+	plan := &SyncPlan{}
+	upstream := map[string]bool{}
+
+	err := filepath.WalkDir(srcDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !previewExts[strings.ToLower(filepath.Ext(path))] {
+			return nil
+		}
+
+		rel, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		upstream[rel] = true
+
+		local, err := os.ReadFile(filepath.Join(dstDir, rel))
+		if os.IsNotExist(err) {
+			plan.Added = append(plan.Added, rel)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		staged, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(local, staged) {
+			plan.Changed = append(plan.Changed, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not compare metadata: %w", err)
+	}
+
+	err = filepath.WalkDir(dstDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			// temp dirs (incl. the staged repo) and unmatched downloads
+			if path != dstDir && (strings.HasPrefix(d.Name(), ".") || d.Name() == "strayvideos") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !previewExts[strings.ToLower(filepath.Ext(path))] {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dstDir, path)
+		if err == nil && !upstream[filepath.ToSlash(rel)] {
+			plan.Removed = append(plan.Removed, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	oldIndex, newIndex, renames, err := syncIndexes(srcDir, dstDir)
+	if err != nil {
+		return nil, err
+	}
+
+	plan.Renamed = renames
+	plan.Seasons = diffSeasons(oldIndex, newIndex)
+
+	sort.Strings(plan.Added)
+	sort.Strings(plan.Changed)
+	sort.Strings(plan.Removed)
+
+	return plan, nil
+}
+
+// syncIndexes reads the index installed in dstDir, the one syncing srcDir
+// installs, and the episodes renamed between them. The preview and the sync
+// both go by it, so a dry run shows exactly the renames a sync follows. Must
+// run before srcDir is copied over
+func syncIndexes(srcDir, dstDir string) (oldIndex, newIndex *shared.MetadataIndex, renames []EpisodeRename, err error) {
+	oldIndex, err = readIndex(dstDir)
+	if err != nil {
+		logger.Log(true, "⚠️  Could not read the current metadata index, renamed episodes won't be followed: %v", err)
+		oldIndex = &shared.MetadataIndex{Seasons: map[string]shared.SeasonIndex{}}
+	}
+
+	// from the staged repo, not the target dir: there the .nfo of a renamed
+	// episode sits next to its replacement until the renames are done
+	newIndex, err = stagedIndex(srcDir)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return oldIndex, newIndex, diffEpisodes(oldIndex, newIndex, dstDir), nil
+}
+
+// readIndex reads metadata-index.json from dir, an empty index if there's none
+func readIndex(dir string) (*shared.MetadataIndex, error) {
+	index := &shared.MetadataIndex{Seasons: map[string]shared.SeasonIndex{}}
+
+	data, err := os.ReadFile(filepath.Join(dir, "metadata-index.json"))
+	if os.IsNotExist(err) {
+		return index, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, index); err != nil {
+		return nil, fmt.Errorf("could not parse metadata index: %w", err)
+	}
+	return index, nil
+}
+
+// the index a sync would install: the one the repo ships, or built from its
+// .nfo files like cloneAndCopyRepo does
+func stagedIndex(srcDir string) (*shared.MetadataIndex, error) {
+	if shared.FileExists(filepath.Join(srcDir, "metadata-index.json")) {
+		return readIndex(srcDir)
+	}
+	return buildIndexFromDir(srcDir)
+}
+
+// episode location in an index
+type episodeAt struct {
+	season, title string
+}
+
+func episodesByRange(index *shared.MetadataIndex) map[string]episodeAt {
+	eps := map[string]episodeAt{}
+	for key, season := range index.Seasons {
+		for cr, ep := range season.EpisodeRange {
+			eps[shared.NormalizeDash(cr)] = episodeAt{key, ep.Title}
+		}
+	}
+	return eps
+}
+
+// diffEpisodes finds chapter ranges whose title or season changed
+func diffEpisodes(oldIndex, newIndex *shared.MetadataIndex, baseDir string) []EpisodeRename {
+	oldEps := episodesByRange(oldIndex)
+	newEps := episodesByRange(newIndex)
+
+	var renames []EpisodeRename
+	for cr, old := range oldEps {
+		cur, ok := newEps[cr]
+		if !ok || cur == old {
+			continue
+		}
+
+		oldBase := filepath.Join(baseDir, old.season, old.title)
+		renames = append(renames, EpisodeRename{
+			ChapterRange: cr,
+			OldSeason:    old.season,
+			OldTitle:     old.title,
+			NewSeason:    cur.season,
+			NewTitle:     cur.title,
+			HasVideo:     shared.FileExists(oldBase+".mkv") || shared.FileExists(oldBase+".mp4"),
+		})
+	}
+
+	sort.Slice(renames, func(i, j int) bool {
+		return rangeLess(renames[i].ChapterRange, renames[j].ChapterRange)
+	})
+	return renames
+}
+
+// diffSeasons lists seasons added, removed, or with a new range or name
+func diffSeasons(oldIndex, newIndex *shared.MetadataIndex) []SeasonChange {
+	var changes []SeasonChange
+
+	for key, cur := range newIndex.Seasons {
+		old, ok := oldIndex.Seasons[key]
+		switch {
+		case !ok:
+			changes = append(changes, SeasonChange{Key: key, New: &cur})
+		case old.Range != cur.Range || old.Name != cur.Name:
+			changes = append(changes, SeasonChange{Key: key, Old: &old, New: &cur})
+		}
+	}
+	for key, old := range oldIndex.Seasons {
+		if _, ok := newIndex.Seasons[key]; !ok {
+			changes = append(changes, SeasonChange{Key: key, Old: &old})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return seasonLess(changes[i].Key, changes[j].Key)
+	})
+	return changes
+}
+
+func rangeLess(a, b string) bool {
+	as, ae := shared.ParseRange(a)
+	bs, be := shared.ParseRange(b)
+	if as != bs {
+		return as < bs
+	}
+	if ae != be {
+		return ae < be
+	}
+	return a < b
+}
+
+// by season number, Specials first
+func seasonLess(a, b string) bool {
+	na, nb := shared.SeasonNumber(a), shared.SeasonNumber(b)
+	if na != nb {
+		return na < nb
+	}
+	return a < b
+}
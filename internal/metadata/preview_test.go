@@ -0,0 +1,185 @@
+package metadata
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"opforjellyfin/internal/shared"
+)
+
+// an episode .nfo the index builder understands
+func episodeNFO(season, chapters string) string {
+	var num int
+	fmt.Sscanf(season, "Season %d", &num)
+	return fmt.Sprintf("<episodedetails><season>%d</season><episode>1</episode><plot>Manga Chapter(s): %s</plot></episodedetails>", num, chapters)
+}
+
+// writes an episode .nfo the index builder understands
+func writeEpisodeNFO(t *testing.T, dir, season, title, chapters string) {
+	t.Helper()
+
+	content := episodeNFO(season, chapters)
+	path := filepath.Join(dir, season, title+".nfo")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// indexes dir and writes metadata-index.json, as a sync would have
+func writeIndex(t *testing.T, dir string) {
+	t.Helper()
+
+	index, err := buildIndexFromDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(index)
+	if err := os.WriteFile(filepath.Join(dir, "metadata-index.json"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDiffSync(t *testing.T) {
+	dst := t.TempDir()
+	writeEpisodeNFO(t, dst, "Season 1", "Romance Dawn 01", "1-3")
+	writeEpisodeNFO(t, dst, "Season 1", "Romance Dawn 02", "4-7")
+	writeEpisodeNFO(t, dst, "Season 2", "Orange Town 01", "8-11")
+	writeIndex(t, dst)
+	os.WriteFile(filepath.Join(dst, "Season 1", "Romance Dawn 02.mkv"), []byte("video"), 0644)
+
+	// unrelated to metadata, must not show up
+	os.MkdirAll(filepath.Join(dst, "strayvideos"), 0755)
+	os.WriteFile(filepath.Join(dst, "strayvideos", "x.nfo"), nil, 0644)
+
+	src := t.TempDir()
+	writeEpisodeNFO(t, src, "Season 1", "Romance Dawn 01", "1-3")
+	writeEpisodeNFO(t, src, "Season 1", "Romance Dawn 02 - Renamed", "4-7")
+	writeEpisodeNFO(t, src, "Season 2", "Orange Town 01", "8-12")
+	writeEpisodeNFO(t, src, "Season 3", "Syrup Village 01", "13-15")
+	os.WriteFile(filepath.Join(src, "Season 3", "poster.png"), []byte("png"), 0644)
+
+	plan, err := diffSync(src, dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantAdded := []string{"Season 1/Romance Dawn 02 - Renamed.nfo", "Season 3/Syrup Village 01.nfo", "Season 3/poster.png"}
+	if !reflect.DeepEqual(plan.Added, wantAdded) {
+		t.Errorf("added = %v, want %v", plan.Added, wantAdded)
+	}
+	if !reflect.DeepEqual(plan.Changed, []string{"Season 2/Orange Town 01.nfo"}) {
+		t.Errorf("changed = %v", plan.Changed)
+	}
+	if !reflect.DeepEqual(plan.Removed, []string{"Season 1/Romance Dawn 02.nfo"}) {
+		t.Errorf("removed = %v", plan.Removed)
+	}
+
+	if len(plan.Renamed) != 1 {
+		t.Fatalf("renamed = %+v, want one", plan.Renamed)
+	}
+	r := plan.Renamed[0]
+	if r.ChapterRange != "4-7" || r.OldTitle != "Romance Dawn 02" || r.NewTitle != "Romance Dawn 02 - Renamed" || !r.HasVideo {
+		t.Errorf("rename = %+v", r)
+	}
+
+	// season 2 grew, season 3 is new
+	if len(plan.Seasons) != 2 {
+		t.Fatalf("seasons = %+v, want two", plan.Seasons)
+	}
+	if c := plan.Seasons[0]; c.Key != "Season 2" || c.Old.Range != "8-11" || c.New.Range != "8-12" {
+		t.Errorf("season change = %+v", c)
+	}
+	if c := plan.Seasons[1]; c.Key != "Season 3" || c.Old != nil {
+		t.Errorf("season change = %+v", c)
+	}
+
+	// a preview must not write anything
+	if _, err := os.Stat(filepath.Join(dst, "Season 3")); !os.IsNotExist(err) {
+		t.Error("diff wrote to the target dir")
+	}
+}
+
+// videos under dir, relative
+func listVideos(t *testing.T, dir string) []string {
+	t.Helper()
+
+	var videos []string
+	filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() && filepath.Ext(path) == ".mkv" {
+			rel, _ := filepath.Rel(dir, path)
+			videos = append(videos, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	sort.Strings(videos)
+	return videos
+}
+
+func TestPreviewMatchesApply(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	base := t.TempDir()
+	cfg, _ := shared.LoadConfig()
+	cfg.TargetDir = base
+	cfg.GitHubRepo = "owner/repo"
+
+	writeEpisodeNFO(t, base, "Season 1", "Romance Dawn 01", "1-3")
+	writeEpisodeNFO(t, base, "Season 1", "Romance Dawn 02", "4-7")
+	writeEpisodeNFO(t, base, "Season 2", "Orange Town 01", "8-11")
+	writeEpisodeNFO(t, base, "Season 2", "Orange Town 02", "12-14")
+	writeIndex(t, base)
+	for _, video := range []string{"Season 1/Romance Dawn 01.mkv", "Season 1/Romance Dawn 02.mkv", "Season 2/Orange Town 01.mkv"} {
+		os.WriteFile(filepath.Join(base, video), []byte(video), 0644)
+	}
+
+	// renamed in place, renamed with a title sorting after the old one, and
+	// moved to another season without a video to follow
+	serveRepo(t, map[string]string{
+		"One Pace/Season 1/Romance Dawn 01.nfo":   episodeNFO("Season 1", "1-3"),
+		"One Pace/Season 1/Romance Dawn - 02.nfo": episodeNFO("Season 1", "4-7"),
+		"One Pace/Season 2/Orange Town 1.nfo":     episodeNFO("Season 2", "8-11"),
+		"One Pace/Season 3/Syrup Village 01.nfo":  episodeNFO("Season 3", "12-14"),
+	})
+
+	plan, err := PreviewSync(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Renamed) != 3 {
+		t.Fatalf("renamed = %+v, want three", plan.Renamed)
+	}
+
+	// the videos there should be once what the preview promised is done
+	want := map[string]bool{}
+	for _, v := range listVideos(t, base) {
+		want[v] = true
+	}
+	for _, r := range plan.Renamed {
+		if r.HasVideo {
+			delete(want, r.OldSeason+"/"+r.OldTitle+".mkv")
+			want[r.NewSeason+"/"+r.NewTitle+".mkv"] = true
+		}
+	}
+	var wantVideos []string
+	for v := range want {
+		wantVideos = append(wantVideos, v)
+	}
+	sort.Strings(wantVideos)
+
+	if err := ApplyPreview(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := listVideos(t, base); !reflect.DeepEqual(got, wantVideos) {
+		t.Errorf("after apply: videos %v, the preview promised %v", got, wantVideos)
+	}
+}
@@ -0,0 +1,195 @@
+// metadata/rename.go
+package metadata
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"opforjellyfin/internal/library"
+	"opforjellyfin/internal/logger"
+	"opforjellyfin/internal/shared"
+)
+
+var (
+	videoExts    = map[string]bool{".mkv": true, ".mp4": true}
+	subtitleExts = map[string]bool{".srt": true, ".ass": true, ".ssa": true, ".vtt": true, ".sub": true, ".idx": true}
+
+	// what may sit between title and extension of a sidecar subtitle, e.g.
+	// ".en" or ".eng.forced". Letters only, so "Title 02.5.srt" is not a
+	// sidecar of "Title 02"
+	sidecarTagRe = regexp.MustCompile(`^(\.[A-Za-z-]{1,12})*$`)
+)
+
+// applyRenames follows episodes renamed upstream: videos and their sidecar
+// subtitles are renamed to the new title, and the old .nfo is removed once its
+// replacement is in place. Returns how many episodes were moved over.
+//
+// Renames can chain (A → B while B → C) or swap, so every file is first moved
+// to a temporary name and only then to its new one - a target is free by the
+// time anything is moved onto it.
+func applyRenames(baseDir string, renames []EpisodeRename) int {
+	moves, ok := planMoves(baseDir, renames)
+	moved := make([]int, len(renames))
+
+	staged := moves[:0]
+	for _, m := range moves {
+		if err := shared.PlaceFile(m.src, m.tmp, false); err != nil {
+			logger.Log(true, "⚠️  Could not rename %s: %v", filepath.Base(m.src), err)
+			ok[m.rename] = false
+			continue
+		}
+		staged = append(staged, m)
+	}
+
+	for _, m := range staged {
+		// left in place by a move that failed above
+		err := os.ErrExist
+		if !shared.FileExists(m.dst) {
+			err = shared.PlaceFile(m.tmp, m.dst, false)
+		}
+		if err != nil {
+			logger.Log(true, "⚠️  Could not rename %s: %v", filepath.Base(m.src), err)
+			ok[m.rename] = false
+			restoreErr := os.ErrExist
+			if !shared.FileExists(m.src) {
+				restoreErr = shared.PlaceFile(m.tmp, m.src, false)
+			}
+			if restoreErr != nil {
+				logger.Log(true, "⚠️  Could not move %s back, it is at %s: %v", filepath.Base(m.src), m.tmp, restoreErr)
+			}
+			continue
+		}
+		moved[m.rename]++
+
+		if m.video {
+			if err := library.Rename(m.oldRel, m.newRel); err != nil {
+				logger.Log(false, "metadata: could not update library for %s: %v", m.newRel, err)
+			}
+		}
+	}
+
+	// an old title that is another episode's new one holds that episode's
+	// .nfo now
+	newNFOs := make(map[string]bool)
+	for _, r := range renames {
+		newNFOs[filepath.Join(baseDir, r.NewSeason, r.NewTitle+".nfo")] = true
+	}
+
+	done := 0
+	for i, r := range renames {
+		if moved[i] > 0 {
+			logger.Log(true, "✏️  Renamed [%s] %s → %s", r.ChapterRange, r.OldTitle, r.NewTitle)
+		}
+
+		// the old .nfo would show up as a second, empty episode - but without
+		// a replacement, or with a video left behind under the old title, it
+		// is all the metadata there is
+		oldNFO := filepath.Join(baseDir, r.OldSeason, r.OldTitle+".nfo")
+		newNFO := filepath.Join(baseDir, r.NewSeason, r.NewTitle+".nfo")
+		if ok[i] && !newNFOs[oldNFO] && shared.FileExists(newNFO) && shared.FileExists(oldNFO) {
+			if err := os.Remove(oldNFO); err != nil {
+				logger.Log(true, "⚠️  Could not remove %s: %v", filepath.Base(oldNFO), err)
+				ok[i] = false
+			}
+		}
+
+		if ok[i] {
+			done++
+		}
+	}
+
+	return done
+}
+
+// one file of an episode rename
+type fileMove struct {
+	src, tmp, dst  string
+	oldRel, newRel string // for the library
+	video          bool
+	rename         int // index into the renames
+}
+
+// planMoves lists the files of every rename, and reports per rename whether
+// all of them can go. A target that exists and isn't moved away itself is
+// never overwritten
+func planMoves(baseDir string, renames []EpisodeRename) ([]fileMove, []bool) {
+	var moves []fileMove
+	ok := make([]bool, len(renames))
+
+	for i, r := range renames {
+		ok[i] = true
+		oldDir := filepath.Join(baseDir, r.OldSeason)
+
+		files, err := os.ReadDir(oldDir)
+		if err != nil {
+			// season folder gone, nothing placed to follow
+			ok[i] = false
+			continue
+		}
+
+		for _, f := range files {
+			suffix, isMedia := episodeSuffix(f.Name(), r.OldTitle)
+			if f.IsDir() || !isMedia {
+				continue
+			}
+
+			moves = append(moves, fileMove{
+				src:    filepath.Join(oldDir, f.Name()),
+				tmp:    filepath.Join(oldDir, f.Name()+".opfor-rename"),
+				dst:    filepath.Join(baseDir, r.NewSeason, r.NewTitle+suffix),
+				oldRel: filepath.Join(r.OldSeason, f.Name()),
+				newRel: filepath.Join(r.NewSeason, r.NewTitle+suffix),
+				video:  videoExts[strings.ToLower(filepath.Ext(suffix))],
+				rename: i,
+			})
+		}
+	}
+
+	// a move that can't go leaves its file in place, which may block another
+	// one - so until nothing changes
+	for {
+		leaving := make(map[string]bool)
+		for _, m := range moves {
+			leaving[m.src] = true
+		}
+
+		taken := make(map[string]bool)
+		var planned []fileMove
+		for _, m := range moves {
+			exists := shared.FileExists(m.dst) && !leaving[m.dst]
+			if exists || taken[m.dst] {
+				logger.Log(true, "⚠️  Not renaming %s: %s already exists", filepath.Base(m.src), filepath.Base(m.dst))
+				ok[m.rename] = false
+				continue
+			}
+			taken[m.dst] = true
+			planned = append(planned, m)
+		}
+
+		if len(planned) == len(moves) {
+			return planned, ok
+		}
+		moves = planned
+	}
+}
+
+// episodeSuffix reports whether name is a video or sidecar subtitle of the
+// episode title, and returns what follows the title, e.g. ".mkv" or ".en.srt"
+func episodeSuffix(name, title string) (string, bool) {
+	if !strings.HasPrefix(name, title+".") {
+		return "", false
+	}
+	suffix := name[len(title):]
+	ext := strings.ToLower(filepath.Ext(suffix))
+
+	switch {
+	case videoExts[ext]:
+		return suffix, len(suffix) == len(ext)
+	case subtitleExts[ext]:
+		return suffix, sidecarTagRe.MatchString(suffix[:len(suffix)-len(ext)])
+	}
+
+	return "", false
+}
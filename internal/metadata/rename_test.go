@@ -0,0 +1,175 @@
+package metadata
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"opforjellyfin/internal/library"
+	"opforjellyfin/internal/shared"
+)
+
+func TestRenameEpisode(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	base := t.TempDir()
+	cfg, _ := shared.LoadConfig()
+	cfg.TargetDir = base
+
+	season := filepath.Join(base, "Season 1")
+	os.MkdirAll(season, 0755)
+	for _, name := range []string{
+		"Dawn 02.nfo", "Dawn 02 - New.nfo", // old and replacement
+		"Dawn 02.mkv", "Dawn 02.en.srt", "Dawn 02.ass",
+		"Dawn 02.5.mkv", "Dawn 02.5.srt", // another episode, not sidecars
+	} {
+		os.WriteFile(filepath.Join(season, name), []byte(name), 0644)
+	}
+	if err := library.Put(library.Record{Path: "Season 1/Dawn 02.mkv", Quality: "1080p"}); err != nil {
+		t.Fatal(err)
+	}
+
+	r := EpisodeRename{ChapterRange: "4-7", OldSeason: "Season 1", OldTitle: "Dawn 02", NewSeason: "Season 1", NewTitle: "Dawn 02 - New"}
+	if applyRenames(base, []EpisodeRename{r}) != 1 {
+		t.Fatal("rename reported failure")
+	}
+
+	for _, name := range []string{"Dawn 02 - New.mkv", "Dawn 02 - New.en.srt", "Dawn 02 - New.ass", "Dawn 02 - New.nfo", "Dawn 02.5.mkv", "Dawn 02.5.srt"} {
+		if !shared.FileExists(filepath.Join(season, name)) {
+			t.Errorf("%s missing", name)
+		}
+	}
+	for _, name := range []string{"Dawn 02.mkv", "Dawn 02.en.srt", "Dawn 02.nfo"} {
+		if shared.FileExists(filepath.Join(season, name)) {
+			t.Errorf("%s still there", name)
+		}
+	}
+
+	lib, _ := library.Load()
+	if _, ok := lib.Get("Season 1/Dawn 02 - New.mkv"); !ok {
+		t.Error("library record not moved")
+	}
+	if _, ok := lib.Get("Season 1/Dawn 02.mkv"); ok {
+		t.Error("library still has the old path")
+	}
+}
+
+func TestRenameEpisodeKeepsNFOWithoutReplacement(t *testing.T) {
+	base := t.TempDir()
+	season := filepath.Join(base, "Season 1")
+	os.MkdirAll(season, 0755)
+	os.WriteFile(filepath.Join(season, "Dawn 02.nfo"), nil, 0644)
+	// something already sits at the new name, so the video can't follow
+	os.WriteFile(filepath.Join(season, "Dawn 02.mkv"), []byte("old"), 0644)
+	os.WriteFile(filepath.Join(season, "Dawn 03.mkv"), []byte("taken"), 0644)
+
+	r := EpisodeRename{OldSeason: "Season 1", OldTitle: "Dawn 02", NewSeason: "Season 1", NewTitle: "Dawn 03"}
+	os.WriteFile(filepath.Join(season, "Dawn 03.nfo"), nil, 0644)
+	if applyRenames(base, []EpisodeRename{r}) != 0 {
+		t.Error("rename onto an existing video reported success")
+	}
+	if data, _ := os.ReadFile(filepath.Join(season, "Dawn 03.mkv")); string(data) != "taken" {
+		t.Error("existing video was overwritten")
+	}
+	if !shared.FileExists(filepath.Join(season, "Dawn 02.nfo")) {
+		t.Error("old .nfo removed although its video stayed behind")
+	}
+
+	// no replacement .nfo upstream
+	os.Remove(filepath.Join(season, "Dawn 03.nfo"))
+	os.Remove(filepath.Join(season, "Dawn 02.mkv"))
+	r.NewTitle = "Dawn 04"
+	applyRenames(base, []EpisodeRename{r})
+	if !shared.FileExists(filepath.Join(season, "Dawn 02.nfo")) {
+		t.Error("old .nfo removed without a replacement")
+	}
+}
+
+func TestApplyRenamesChained(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	base := t.TempDir()
+	season := filepath.Join(base, "Season 1")
+	os.MkdirAll(season, 0755)
+	// after the sync, each .nfo already holds the range it is named for now
+	for name, content := range map[string]string{
+		"Ep A.mkv": "1-1", "Ep B.mkv": "2-2", "Ep B.en.srt": "2-2",
+		"Ep X.mkv": "3-3", "Ep Y.mkv": "4-4",
+		"Ep A.nfo": "old", "Ep B.nfo": "1-1", "Ep C.nfo": "2-2",
+	} {
+		os.WriteFile(filepath.Join(season, name), []byte(content), 0644)
+	}
+
+	renames := []EpisodeRename{
+		// A → B while B → C, in the order that used to fail
+		{ChapterRange: "1-1", OldSeason: "Season 1", OldTitle: "Ep A", NewSeason: "Season 1", NewTitle: "Ep B"},
+		{ChapterRange: "2-2", OldSeason: "Season 1", OldTitle: "Ep B", NewSeason: "Season 1", NewTitle: "Ep C"},
+		// and a swap
+		{ChapterRange: "3-3", OldSeason: "Season 1", OldTitle: "Ep X", NewSeason: "Season 1", NewTitle: "Ep Y"},
+		{ChapterRange: "4-4", OldSeason: "Season 1", OldTitle: "Ep Y", NewSeason: "Season 1", NewTitle: "Ep X"},
+	}
+	if n := applyRenames(base, renames); n != len(renames) {
+		t.Fatalf("%d of %d renames done", n, len(renames))
+	}
+
+	for name, want := range map[string]string{
+		"Ep B.mkv": "1-1", "Ep C.mkv": "2-2", "Ep C.en.srt": "2-2",
+		"Ep Y.mkv": "3-3", "Ep X.mkv": "4-4",
+		"Ep B.nfo": "1-1", "Ep C.nfo": "2-2",
+	} {
+		if data, err := os.ReadFile(filepath.Join(season, name)); err != nil || string(data) != want {
+			t.Errorf("%s: got %q (%v), want %q", name, data, err, want)
+		}
+	}
+	for _, name := range []string{"Ep A.mkv", "Ep A.nfo", "Ep B.en.srt"} {
+		if shared.FileExists(filepath.Join(season, name)) {
+			t.Errorf("%s still there", name)
+		}
+	}
+}
+
+func TestSyncFollowsRenameWithBuiltIndex(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	base := t.TempDir()
+	cfg, _ := shared.LoadConfig()
+	cfg.TargetDir = base
+	cfg.GitHubRepo = "owner/repo"
+
+	writeEpisodeNFO(t, base, "Season 1", "Romance Dawn 01", "1-3")
+	writeEpisodeNFO(t, base, "Season 1", "Romance Dawn 02", "4-7")
+	writeIndex(t, base)
+	os.WriteFile(filepath.Join(base, "Season 1", "Romance Dawn 02.mkv"), []byte("video"), 0644)
+
+	// no metadata-index.json upstream, and the new title sorts before the
+	// old one - indexing the target dir, the old .nfo would win
+	serveRepo(t, map[string]string{
+		"One Pace/Season 1/Romance Dawn 01.nfo":   episodeNFO("Season 1", "1-3"),
+		"One Pace/Season 1/Romance Dawn - 02.nfo": episodeNFO("Season 1", "4-7"),
+	})
+
+	if err := SyncMetadata(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	season := filepath.Join(base, "Season 1")
+	if !shared.FileExists(filepath.Join(season, "Romance Dawn - 02.mkv")) {
+		t.Error("video not renamed")
+	}
+	for _, name := range []string{"Romance Dawn 02.mkv", "Romance Dawn 02.nfo"} {
+		if shared.FileExists(filepath.Join(season, name)) {
+			t.Errorf("%s still there", name)
+		}
+	}
+
+	index, err := readIndex(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := index.Seasons["Season 1"].EpisodeRange["4-7"].Title; got != "Romance Dawn - 02" {
+		t.Errorf("index has %q for 4-7, want the new title", got)
+	}
+}
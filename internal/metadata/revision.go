@@ -0,0 +1,204 @@
+// metadata/revision.go
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"opforjellyfin/internal/shared"
+)
+
+// Revision is the metadata repo commit installed in the target dir, stored in
+// metadata-revision.json next to metadata-index.json
+type Revision struct {
+	Repo     string    `json:"repo"`
+	Ref      string    `json:"ref,omitempty"` // the pin it was synced from, empty = default branch
+	Commit   string    `json:"commit"`
+	SyncedAt time.Time `json:"synced_at"`
+}
+
+// Short is the abbreviated commit, as git prints it
+func (r Revision) Short() string {
+	return shortCommit(r.Commit)
+}
+
+// RefName is the ref for display
+func (r Revision) RefName() string {
+	return refName(r.Ref)
+}
+
+func shortCommit(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
+
+func refName(ref string) string {
+	if ref == "" {
+		return "default branch"
+	}
+	return ref
+}
+
+// a full commit id needs no lookup
+var commitRe = regexp.MustCompile(`^[0-9a-fA-F]{40}$`)
+
+func revisionPath(baseDir string) string {
+	return filepath.Join(baseDir, "metadata-revision.json")
+}
+
+// LoadRevision reads the installed revision. Returns nil when the target dir
+// was synced before revisions were recorded
+func LoadRevision(baseDir string) (*Revision, error) {
+	data, err := os.ReadFile(revisionPath(baseDir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var rev Revision
+	if err := json.Unmarshal(data, &rev); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %w", revisionPath(baseDir), err)
+	}
+
+	return &rev, nil
+}
+
+func saveRevision(baseDir string, rev Revision) error {
+	data, err := json.MarshalIndent(rev, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return shared.WriteFileAtomic(revisionPath(baseDir), data, 0644)
+}
+
+// UpstreamStatus compares the installed revision with what the configured
+// ref currently points at
+type UpstreamStatus struct {
+	Installed *Revision // nil if unknown
+	Ref       string
+	Upstream  string // commit the ref resolves to
+}
+
+// UpToDate reports whether the installed commit is the upstream one
+func (s UpstreamStatus) UpToDate() bool {
+	return s.Installed != nil && s.Installed.Commit == s.Upstream
+}
+
+// CheckUpstream resolves cfg's ref without fetching or changing anything
+func CheckUpstream(cfg *shared.Config) (UpstreamStatus, error) {
+	status := UpstreamStatus{Ref: cfg.MetadataRef}
+
+	installed, err := LoadRevision(cfg.TargetDir)
+	if err != nil {
+		return status, err
+	}
+	status.Installed = installed
+
+	status.Upstream, err = resolveRevision(cfg.GitHubRepo, cfg.MetadataRef)
+	return status, err
+}
+
+// resolveRevision asks GitHub which commit ref (branch, tag or commit, empty
+// for the default branch) points at
+func resolveRevision(repo, ref string) (string, error) {
+	if commitRe.MatchString(ref) {
+		return strings.ToLower(ref), nil
+	}
+	apiRef := ref
+	if apiRef == "" {
+		apiRef = "HEAD"
+	}
+
+	url := fmt.Sprintf("%s/repos/%s/commits/%s", githubAPI, repo, apiRef)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	// makes the API answer with just the sha
+	req.Header.Set("Accept", "application/vnd.github.sha")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("could not resolve %s@%s: %s", repo, refName(ref), resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if err != nil {
+		return "", err
+	}
+
+	sha := strings.TrimSpace(string(body))
+	if !commitRe.MatchString(sha) {
+		return "", fmt.Errorf("unexpected answer resolving %s@%s: %q", repo, refName(ref), sha)
+	}
+
+	return strings.ToLower(sha), nil
+}
+
+// UpstreamFiles downloads the installed revision of the metadata repo (or
+// cfg's ref if none is recorded) and lists the files it has, as slash
+// separated paths relative to the target dir
+func UpstreamFiles(cfg *shared.Config) (map[string]bool, Revision, error) {
+	rev := Revision{Repo: cfg.GitHubRepo, Ref: cfg.MetadataRef}
+	ref := cfg.MetadataRef
+
+	installed, err := LoadRevision(cfg.TargetDir)
+	if err != nil {
+		return nil, rev, err
+	}
+	if installed != nil {
+		rev.Ref = installed.Ref
+		ref = installed.Commit
+	}
+
+	tmpDir, commit, cleanup, err := stageRepo(cfg, ref)
+	if err != nil {
+		return nil, rev, err
+	}
+	defer cleanup()
+	rev.Commit = commit
+
+	files, err := listFiles(filepath.Join(tmpDir, metadataSubtree))
+	return files, rev, err
+}
+
+// the files under dir, relative and slash separated
+func listFiles(dir string) (map[string]bool, error) {
+	files := map[string]bool{}
+
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = true
+		return nil
+	})
+
+	return files, err
+}
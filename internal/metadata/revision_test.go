@@ -0,0 +1,59 @@
+package metadata
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"opforjellyfin/internal/shared"
+)
+
+func TestCheckUpstream(t *testing.T) {
+	upstream := testCommit
+	var requested []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.Path)
+		w.Write([]byte(upstream + "\n"))
+	}))
+	defer srv.Close()
+
+	old := githubAPI
+	githubAPI = srv.URL
+	defer func() { githubAPI = old }()
+
+	cfg := &shared.Config{TargetDir: t.TempDir(), GitHubRepo: "owner/repo", MetadataRef: "v2"}
+
+	// nothing recorded yet
+	status, err := CheckUpstream(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Installed != nil || status.UpToDate() {
+		t.Errorf("got %+v, want an unknown installed revision", status)
+	}
+	if requested[0] != "/repos/owner/repo/commits/v2" {
+		t.Errorf("resolved %s, want the pinned ref", requested[0])
+	}
+
+	rev := Revision{Repo: "owner/repo", Ref: "v2", Commit: testCommit, SyncedAt: time.Now()}
+	if err := saveRevision(cfg.TargetDir, rev); err != nil {
+		t.Fatal(err)
+	}
+
+	if status, _ := CheckUpstream(cfg); !status.UpToDate() {
+		t.Errorf("got %+v, want up to date", status)
+	}
+
+	upstream = "fedcba9876543210fedcba9876543210fedcba98"
+	if status, _ := CheckUpstream(cfg); status.UpToDate() || status.Installed.Short() != "0123456" {
+		t.Errorf("got %+v, want upstream moved past 0123456", status)
+	}
+
+	// a full commit pin is its own answer
+	requested = nil
+	cfg.MetadataRef = testCommit
+	if status, _ := CheckUpstream(cfg); !status.UpToDate() || len(requested) != 0 {
+		t.Errorf("commit pin: got %+v after %d requests", status, len(requested))
+	}
+}
@@ -0,0 +1,272 @@
+// prune/prune.go
+package prune
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"opforjellyfin/internal/library"
+	"opforjellyfin/internal/shared"
+)
+
+// Options choose what beyond the always-safe cleanup may be removed
+type Options struct {
+	Videos  bool // videos without a matching .nfo
+	Partial bool // partial downloads, which 'resume' could still continue
+}
+
+// Plan is everything prune found, paths relative to the target dir and slash
+// separated. Only Removals() is ever deleted
+type Plan struct {
+	StaleNFO     []string // .nfo files the metadata repo no longer has
+	KeptNFO      []string // stale, but the only metadata of a video that stays
+	OrphanVideos []string // videos with no (or only a stale) .nfo
+	EmptyDirs    []string // folders with nothing left in them
+	Leftovers    []string // temp files and dirs of finished or failed runs
+	Partials     []string // partial downloads
+	Renaming     []string // files an interrupted rename left under a temp name, never removed
+	Busy         bool     // a download session is running, temp files are left alone
+
+	opts    Options
+	baseDir string
+}
+
+const (
+	tmpDirName     = ".opfor-tmp"
+	repoStagingDir = "repo-tmp"      // where a metadata sync unpacks the repo
+	renameSuffix   = ".opfor-rename" // a file halfway through a metadata rename
+)
+
+// Find works out what to prune in baseDir. upstream is the set of files the
+// metadata repo has (see metadata.UpstreamFiles). Nothing is touched
+func Find(baseDir string, upstream map[string]bool, opts Options) (*Plan, error) {
+	if len(upstream) == 0 {
+		// would make every .nfo stale
+		return nil, errors.New("no upstream metadata to compare with")
+	}
+
+	p := &Plan{opts: opts, baseDir: baseDir}
+
+	// temp files are only leftovers if no session may be writing them
+	state, err := shared.LoadPublishedDownloads()
+	p.Busy = err == nil && state != nil && time.Since(state.UpdatedAt) <= shared.StaleAfter
+
+	stale := map[string]bool{}
+	var videos []string
+
+	err = filepath.WalkDir(baseDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(baseDir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if d.IsDir() {
+			// our temp dir is handled below, other dotdirs aren't ours.
+			// strayvideos holds unmatched downloads the user sorts by hand
+			if path != baseDir && (strings.HasPrefix(d.Name(), ".") || d.Name() == "strayvideos") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		// a placement or atomic write that never got renamed into place
+		if strings.Contains(d.Name(), ".opfor-part-") {
+			if !p.Busy {
+				p.Leftovers = append(p.Leftovers, rel)
+			}
+			return nil
+		}
+
+		// the only copy of the file, so it's shown for the user to name back
+		if strings.HasSuffix(d.Name(), renameSuffix) {
+			if !p.syncing() {
+				p.Renaming = append(p.Renaming, rel)
+			}
+			return nil
+		}
+
+		switch strings.ToLower(filepath.Ext(d.Name())) {
+		case ".nfo":
+			if !upstream[rel] {
+				stale[rel] = true
+			}
+		case ".mkv", ".mp4":
+			videos = append(videos, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// a stale .nfo still describes the video next to it - Jellyfin would
+	// lose the episode if it went while the video stays
+	hasVideo := map[string]bool{}
+	for _, v := range videos {
+		nfo := strings.TrimSuffix(v, filepath.Ext(v)) + ".nfo"
+		if stale[nfo] || !shared.FileExists(filepath.Join(baseDir, filepath.FromSlash(nfo))) {
+			p.OrphanVideos = append(p.OrphanVideos, v)
+			hasVideo[nfo] = true
+		}
+	}
+	for nfo := range stale {
+		if hasVideo[nfo] && !opts.Videos {
+			p.KeptNFO = append(p.KeptNFO, nfo)
+		} else {
+			p.StaleNFO = append(p.StaleNFO, nfo)
+		}
+	}
+
+	if err := p.findTemp(); err != nil {
+		return nil, err
+	}
+
+	removed := map[string]bool{}
+	for _, rel := range p.Removals() {
+		removed[rel] = true
+	}
+	p.findEmptyDirs(removed)
+
+	sort.Strings(p.StaleNFO)
+	sort.Strings(p.KeptNFO)
+	sort.Strings(p.OrphanVideos)
+	sort.Strings(p.Leftovers)
+	sort.Strings(p.Partials)
+	sort.Strings(p.Renaming)
+
+	return p, nil
+}
+
+// findTemp sorts the contents of .opfor-tmp into partial downloads and
+// leftovers, unless a session is using them right now
+func (p *Plan) findTemp() error {
+	if p.Busy {
+		return nil
+	}
+
+	entries, err := os.ReadDir(filepath.Join(p.baseDir, tmpDirName))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		rel := tmpDirName + "/" + e.Name()
+		if e.Name() == repoStagingDir && p.syncing() {
+			continue
+		}
+		if e.IsDir() && strings.HasPrefix(e.Name(), "opfor-tmp-") {
+			p.Partials = append(p.Partials, rel)
+		} else {
+			p.Leftovers = append(p.Leftovers, rel)
+		}
+	}
+	return nil
+}
+
+// syncing reports whether a metadata sync holds its staging dir right now
+func (p *Plan) syncing() bool {
+	return shared.PathLocked(filepath.Join(p.baseDir, tmpDirName, repoStagingDir))
+}
+
+// findEmptyDirs lists folders holding nothing once removed is gone. The
+// target dir itself, and the folders skipped by Find, are never listed
+func (p *Plan) findEmptyDirs(removed map[string]bool) {
+	var walk func(dir, rel string) bool
+	walk = func(dir, rel string) bool {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return false
+		}
+
+		empty := true
+		for _, e := range entries {
+			childRel := e.Name()
+			if rel != "" {
+				childRel = rel + "/" + e.Name()
+			}
+
+			switch {
+			case !e.IsDir():
+				if !removed[childRel] {
+					empty = false
+				}
+			case strings.HasPrefix(e.Name(), ".") || e.Name() == "strayvideos":
+				empty = false
+			case !walk(filepath.Join(dir, e.Name()), childRel):
+				empty = false
+			}
+		}
+
+		if empty && rel != "" {
+			p.EmptyDirs = append(p.EmptyDirs, rel)
+		}
+		return empty
+	}
+
+	walk(p.baseDir, "")
+}
+
+// Removals is exactly what Apply deletes, in the order it does: files, then
+// temp dirs, then the folders they leave empty (deepest first)
+func (p *Plan) Removals() []string {
+	var out []string
+	out = append(out, p.StaleNFO...)
+	if p.opts.Videos {
+		out = append(out, p.OrphanVideos...)
+	}
+	out = append(out, p.Leftovers...)
+	if p.opts.Partial {
+		out = append(out, p.Partials...)
+	}
+	// findEmptyDirs appends children before their parents
+	out = append(out, p.EmptyDirs...)
+	return out
+}
+
+// Apply deletes Removals(). Returns how many were removed and what failed
+func (p *Plan) Apply() (int, error) {
+	removedVideos := map[string]bool{}
+	if p.opts.Videos {
+		for _, v := range p.OrphanVideos {
+			removedVideos[v] = true
+		}
+	}
+
+	var errs []error
+	n := 0
+	for _, rel := range p.Removals() {
+		path := filepath.Join(p.baseDir, filepath.FromSlash(rel))
+
+		var err error
+		if strings.HasPrefix(rel, tmpDirName+"/") {
+			err = os.RemoveAll(path)
+		} else {
+			// a plain Remove, so a folder that gained files since Find stays
+			err = os.Remove(path)
+		}
+		if err != nil && !os.IsNotExist(err) {
+			errs = append(errs, err)
+			continue
+		}
+		n++
+
+		if removedVideos[rel] {
+			if err := library.Remove(rel); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	return n, errors.Join(errs...)
+}
@@ -0,0 +1,145 @@
+package prune
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"opforjellyfin/internal/shared"
+)
+
+// lays out a target dir, paths are slash separated
+func writeTree(t *testing.T, base string, paths ...string) {
+	t.Helper()
+	for _, p := range paths {
+		path := filepath.Join(base, filepath.FromSlash(p))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(p), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestFindAndApply(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	base := t.TempDir()
+	cfg, _ := shared.LoadConfig()
+	cfg.TargetDir = base
+
+	writeTree(t, base,
+		"tvshow.nfo",
+		"Season 1/Dawn 01.nfo", "Season 1/Dawn 01.mkv",
+		"Season 1/Dawn 02.nfo", "Season 1/Dawn 02.mkv", // renamed away upstream
+		"Season 1/Extra.mp4", // never had an .nfo
+		"Season 9/Gone.nfo",  // whole season dropped
+		"Season 1/.Dawn 01.mkv.opfor-part-123",
+		"Season 1/Dawn 03.mkv.opfor-rename", // a rename cut off halfway
+		".opfor-tmp/repo-tmp/config.json",
+		".opfor-tmp/opfor-tmp-42/video.mkv",
+		"strayvideos/unknown.mkv",
+	)
+	os.MkdirAll(filepath.Join(base, "Season 10"), 0755)
+
+	upstream := map[string]bool{"tvshow.nfo": true, "Season 1/Dawn 01.nfo": true}
+
+	plan, err := Find(base, upstream, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(name string, got, want []string) {
+		t.Helper()
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s = %v, want %v", name, got, want)
+		}
+	}
+	check("stale", plan.StaleNFO, []string{"Season 9/Gone.nfo"})
+	check("kept", plan.KeptNFO, []string{"Season 1/Dawn 02.nfo"})
+	check("videos", plan.OrphanVideos, []string{"Season 1/Dawn 02.mkv", "Season 1/Extra.mp4"})
+	check("leftovers", plan.Leftovers, []string{".opfor-tmp/repo-tmp", "Season 1/.Dawn 01.mkv.opfor-part-123"})
+	check("partials", plan.Partials, []string{".opfor-tmp/opfor-tmp-42"})
+	check("empty", plan.EmptyDirs, []string{"Season 10", "Season 9"})
+	check("renaming", plan.Renaming, []string{"Season 1/Dawn 03.mkv.opfor-rename"})
+
+	// videos and partial downloads are only listed
+	for _, rel := range plan.Removals() {
+		if rel == "Season 1/Extra.mp4" || rel == ".opfor-tmp/opfor-tmp-42" {
+			t.Errorf("%s would be removed without being asked for", rel)
+		}
+	}
+
+	if _, err := plan.Apply(); err != nil {
+		t.Fatal(err)
+	}
+	// the stale .nfo stays with its video
+	for _, p := range []string{"Season 1/Dawn 02.mkv", "Season 1/Dawn 02.nfo", "Season 1/Extra.mp4", ".opfor-tmp/opfor-tmp-42/video.mkv", "strayvideos/unknown.mkv", "Season 1/Dawn 01.mkv", "Season 1/Dawn 03.mkv.opfor-rename"} {
+		if !shared.FileExists(filepath.Join(base, filepath.FromSlash(p))) {
+			t.Errorf("%s was removed", p)
+		}
+	}
+	for _, p := range []string{"Season 9", ".opfor-tmp/repo-tmp"} {
+		if _, err := os.Stat(filepath.Join(base, filepath.FromSlash(p))); !os.IsNotExist(err) {
+			t.Errorf("%s still there", p)
+		}
+	}
+
+	// asked for, the videos go too, and with them their stale .nfo
+	plan, err = Find(base, upstream, Options{Videos: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	check("stale with --videos", plan.StaleNFO, []string{"Season 1/Dawn 02.nfo"})
+	if _, err := plan.Apply(); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"Extra.mp4", "Dawn 02.mkv", "Dawn 02.nfo"} {
+		if shared.FileExists(filepath.Join(base, "Season 1", p)) {
+			t.Errorf("%s kept with --videos", p)
+		}
+	}
+}
+
+func TestFindRefusesEmptyUpstream(t *testing.T) {
+	if _, err := Find(t.TempDir(), nil, Options{}); err == nil {
+		t.Error("expected an error without upstream metadata")
+	}
+}
+
+func TestFindLeavesRunningSyncAlone(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	base := t.TempDir()
+	cfg, _ := shared.LoadConfig()
+	cfg.TargetDir = base
+
+	writeTree(t, base,
+		"tvshow.nfo",
+		".opfor-tmp/repo-tmp/One Pace/tvshow.nfo",
+		"Season 1/Dawn 01.mkv.opfor-rename",
+	)
+
+	unlock := shared.LockPath(filepath.Join(base, ".opfor-tmp", "repo-tmp"))
+	plan, err := Find(base, map[string]bool{"tvshow.nfo": true}, Options{})
+	unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Leftovers) != 0 || len(plan.Renaming) != 0 {
+		t.Errorf("files of a running sync listed: leftovers %v, renaming %v", plan.Leftovers, plan.Renaming)
+	}
+
+	// once it's done they're what it left behind
+	plan, err = Find(base, map[string]bool{"tvshow.nfo": true}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(plan.Leftovers, []string{".opfor-tmp/repo-tmp"}) || len(plan.Renaming) != 1 {
+		t.Errorf("after the sync: leftovers %v, renaming %v", plan.Leftovers, plan.Renaming)
+	}
+}
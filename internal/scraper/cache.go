@@ -1,14 +1,31 @@
 package scraper
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"opforjellyfin/internal/metadata"
 	"opforjellyfin/internal/shared"
 	"os"
 	"path/filepath"
+	"time"
 )
 
+// CacheTTL is how old the search cache may get before 'download' warns that
+// its keys may no longer match what 'list' would show
+const CacheTTL = 24 * time.Hour
+
 type SearchCache struct {
-	Results []shared.TorrentEntry `json:"results"`
+	FetchedAt  time.Time             `json:"fetched_at"`
+	Sources    []CachedSource        `json:"sources"`     // what was searched
+	ConfigHash string                `json:"config_hash"` // of the source configs active at the time
+	Results    []shared.TorrentEntry `json:"results"`
+}
+
+// a source searched for the cache, and the query it was searched with
+type CachedSource struct {
+	Name  string `json:"name"`
+	Query string `json:"query"`
 }
 
 const cacheFileName = "search_cache.json"
@@ -22,10 +39,17 @@ func cacheFilePath() string {
 	return filepath.Join(shared.ConfigDir(), cacheFileName)
 }
 
-// saves the current search results to cache, returns error if failed
-func SaveSearchCache(results []shared.TorrentEntry) error {
+// saves the current search results to cache, along with where and when they
+// came from. returns error if failed
+func SaveSearchCache(cfg *shared.Config, results []shared.TorrentEntry) error {
 	cache := SearchCache{
-		Results: results,
+		FetchedAt:  time.Now(),
+		ConfigHash: ConfigHash(cfg),
+		Results:    results,
+	}
+
+	for _, src := range cfg.AllSources() {
+		cache.Sources = append(cache.Sources, CachedSource{Name: src.Name, Query: src.SearchQuery})
 	}
 
 	data, err := json.MarshalIndent(cache, "", "  ")
@@ -33,7 +57,46 @@ func SaveSearchCache(results []shared.TorrentEntry) error {
 		return err
 	}
 
-	return os.WriteFile(cacheFilePath(), data, 0644)
+	return shared.WriteFileAtomic(cacheFilePath(), data, 0644)
+}
+
+// ConfigHash identifies the source configs in cfg. Download keys only mean
+// something together with the sources that produced them.
+func ConfigHash(cfg *shared.Config) string {
+	data, _ := json.Marshal(cfg.AllSources())
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+// Age of the cache. Caches from before it was recorded are infinitely old
+func (c *SearchCache) Age() time.Duration {
+	if c.FetchedAt.IsZero() {
+		return time.Duration(1<<63 - 1)
+	}
+	return time.Since(c.FetchedAt)
+}
+
+// IsStale reports whether the cache is older than CacheTTL
+func (c *SearchCache) IsStale() bool {
+	return c.Age() > CacheTTL
+}
+
+// MatchesConfig reports whether the cache was made with the sources currently
+// in cfg. Caches from before this was recorded get the benefit of the doubt.
+func (c *SearchCache) MatchesConfig(cfg *shared.Config) bool {
+	return c.ConfigHash == "" || c.ConfigHash == ConfigHash(cfg)
+}
+
+// CachedResults returns the cached entries with their have/metadata marks
+// recomputed against the library as it is now. No network needed.
+func (c *SearchCache) CachedResults() []shared.TorrentEntry {
+	results := make([]shared.TorrentEntry, len(c.Results))
+	for i, entry := range c.Results {
+		entry.MetaDataAvail = metadata.HaveMetadata(entry.ChapterRange)
+		entry.HaveIt = metadata.HaveVideoStatus(entry.ChapterRange)
+		results[i] = entry
+	}
+	return results
 }
 
 // loads the search cache, returns the adress to the cache and error
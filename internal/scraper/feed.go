@@ -0,0 +1,168 @@
+// scraper/feed.go
+package scraper
+
+import (
+	"encoding/xml"
+	"fmt"
+	"opforjellyfin/internal/shared"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// feedProvider reads a tracker's RSS or Atom feed. One request per search, and
+// no CSS selectors to break when the site's layout changes. The feed URL is
+// BaseURL + SearchPathTemplate, with a single %s for the query.
+type feedProvider struct {
+	config shared.ScraperConfig
+}
+
+// both formats decode into this - xml.Unmarshal doesn't care about the root
+// element's name, and an RSS document simply has no <entry> (or vice versa)
+type feed struct {
+	Items   []feedItem  `xml:"channel>item"` // RSS
+	Entries []atomEntry `xml:"entry"`        // Atom
+}
+
+// RSS item. Seeders, infohash and size aren't part of RSS itself, but most
+// trackers add them in their own namespace (e.g. nyaa:seeders) - the
+// namespace is ignored when matching these.
+type feedItem struct {
+	Title     string `xml:"title"`
+	Link      string `xml:"link"`
+	PubDate   string `xml:"pubDate"`
+	Enclosure struct {
+		URL    string `xml:"url,attr"`
+		Length int64  `xml:"length,attr"`
+	} `xml:"enclosure"`
+	Seeders  string `xml:"seeders"`
+	InfoHash string `xml:"infoHash"`
+	Size     string `xml:"size"`
+}
+
+type atomEntry struct {
+	Title string `xml:"title"`
+	Links []struct {
+		Href string `xml:"href,attr"`
+		Rel  string `xml:"rel,attr"`
+	} `xml:"link"`
+	Updated  string `xml:"updated"`
+	Seeders  string `xml:"seeders"`
+	InfoHash string `xml:"infoHash"`
+	Size     string `xml:"size"`
+}
+
+func (p *feedProvider) Name() string {
+	return p.config.Name
+}
+
+// Search fetches the feed for query and parses every item in it
+func (p *feedProvider) Search(query string) ([]shared.TorrentEntry, error) {
+	feedURL := fmt.Sprintf(p.config.BaseURL+p.config.SearchPathTemplate, query)
+
+	body, err := fetchBody(feedURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var f feed
+	if err := xml.Unmarshal(body, &f); err != nil {
+		return nil, fmt.Errorf("could not parse feed: %w", err)
+	}
+
+	var entries []shared.TorrentEntry
+	for _, item := range f.Items {
+		if entry, ok := buildEntry(item.release(), &p.config); ok {
+			entries = append(entries, entry)
+		}
+	}
+	for _, e := range f.Entries {
+		if entry, ok := buildEntry(e.release(), &p.config); ok {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries, nil
+}
+
+// TorrentURL returns the .torrent link from the feed. Items that only had a
+// magnet or infohash are downloaded through the magnet instead.
+func (p *feedProvider) TorrentURL(entry shared.TorrentEntry) string {
+	return entry.TorrentLink
+}
+
+func (item feedItem) release() release {
+	r := release{
+		title:    strings.TrimSpace(item.Title),
+		seeders:  parseFeedSeeders(item.Seeders),
+		size:     shared.ParseSize(item.Size),
+		infoHash: strings.TrimSpace(item.InfoHash),
+		date:     formatFeedDate(item.PubDate),
+	}
+
+	if r.size == 0 {
+		r.size = item.Enclosure.Length
+	}
+
+	// the enclosure is the torrent itself if there is one, link may just
+	// point at the tracker's page for it
+	for _, link := range []string{item.Enclosure.URL, strings.TrimSpace(item.Link)} {
+		setFeedLink(&r, link)
+	}
+
+	return r
+}
+
+func (e atomEntry) release() release {
+	r := release{
+		title:    strings.TrimSpace(e.Title),
+		seeders:  parseFeedSeeders(e.Seeders),
+		size:     shared.ParseSize(e.Size),
+		infoHash: strings.TrimSpace(e.InfoHash),
+		date:     formatFeedDate(e.Updated),
+	}
+
+	for _, link := range e.Links {
+		if link.Rel == "" || link.Rel == "alternate" || link.Rel == "enclosure" {
+			setFeedLink(&r, link.Href)
+		}
+	}
+
+	return r
+}
+
+// fills the release's torrent or magnet link, whichever link is, unless it
+// already has one
+func setFeedLink(r *release, link string) {
+	switch {
+	case strings.HasPrefix(link, "magnet:"):
+		if r.magnetLink == "" {
+			r.magnetLink = link
+		}
+	case strings.HasSuffix(strings.ToLower(link), ".torrent"):
+		if r.torrentLink == "" {
+			r.torrentLink = link
+		}
+	}
+}
+
+// -1 if the feed doesn't carry seeders
+func parseFeedSeeders(s string) int {
+	seeders, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil {
+		return -1
+	}
+	return seeders
+}
+
+// feeds use RFC 1123 (RSS) or RFC 3339 (Atom) dates - shown the way the
+// trackers' search pages show them
+func formatFeedDate(s string) string {
+	s = strings.TrimSpace(s)
+	for _, layout := range []string{time.RFC1123Z, time.RFC1123, time.RFC3339} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC().Format("2006-01-02 15:04")
+		}
+	}
+	return s
+}
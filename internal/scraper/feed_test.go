@@ -0,0 +1,172 @@
+package scraper
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"opforjellyfin/internal/shared"
+)
+
+// nyaa style: the tracker's fields in its own namespace
+const rssFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:nyaa="https://nyaa.si/xmlns/nyaa">
+  <channel>
+    <title>Tracker</title>
+    <item>
+      <title>[One Pace][1-7] Romance Dawn [1080p]</title>
+      <link>https://tracker.example/view/1</link>
+      <pubDate>Sun, 01 Jun 2025 12:00:00 +0000</pubDate>
+      <enclosure url="https://tracker.example/download/1.torrent" length="999" type="application/x-bittorrent" />
+      <nyaa:seeders>12</nyaa:seeders>
+      <nyaa:infoHash>0123456789abcdef0123456789abcdef01234567</nyaa:infoHash>
+      <nyaa:size>1.5 GiB</nyaa:size>
+    </item>
+    <item>
+      <title>[One Pace][8-11] Orange Town [720p]</title>
+      <link>magnet:?xt=urn:btih:1123456789abcdef0123456789abcdef01234567</link>
+      <pubDate>Mon, 02 Jun 2025 08:30:00 GMT</pubDate>
+    </item>
+  </channel>
+</rss>`
+
+const atomFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <title>Tracker</title>
+  <entry>
+    <title>[One Pace][12-17] Syrup Village [1080p]</title>
+    <link rel="alternate" href="https://tracker.example/view/3" />
+    <link rel="enclosure" href="https://tracker.example/download/3.torrent" />
+    <link rel="related" href="magnet:?xt=urn:btih:2123456789abcdef0123456789abcdef01234567" />
+    <updated>2025-06-03T10:15:00+02:00</updated>
+    <seeders>n/a</seeders>
+    <size>700 MiB</size>
+  </entry>
+  <entry>
+    <title>[One Pace][18-21] Baratie [720p]</title>
+    <link href="magnet:?xt=urn:btih:3123456789abcdef0123456789abcdef01234567" />
+    <updated>2025-06-04T00:00:00Z</updated>
+    <seeders>4</seeders>
+  </entry>
+</feed>`
+
+func searchFeed(t *testing.T, body string) []shared.TorrentEntry {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+	defer srv.Close()
+
+	p := &feedProvider{config: shared.ScraperConfig{
+		Name:               "tracker",
+		Kind:               KindRSS,
+		BaseURL:            srv.URL,
+		SearchPathTemplate: "/?page=rss&q=%s",
+	}}
+
+	entries, err := p.Search("one+pace")
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	return entries
+}
+
+func TestFeedSearchRSS(t *testing.T) {
+	isolateConfig(t)
+
+	entries := searchFeed(t, rssFeed)
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2: %+v", len(entries), entries)
+	}
+
+	first := entries[0]
+	if first.TorrentLink != "https://tracker.example/download/1.torrent" || first.MagnetLink != "" {
+		t.Errorf("enclosure should be the torrent, not the page link: %+v", first)
+	}
+	if first.Seeders != 12 || first.InfoHash != "0123456789abcdef0123456789abcdef01234567" {
+		t.Errorf("namespaced fields not read: %+v", first)
+	}
+	if first.Size != 1536*1024*1024 {
+		t.Errorf("size: got %d, want the tracker's 1.5 GiB over the enclosure length", first.Size)
+	}
+	if first.Date != "2025-06-01 12:00" {
+		t.Errorf("date: got %q", first.Date)
+	}
+
+	second := entries[1]
+	if second.MagnetLink == "" || second.TorrentLink != "" {
+		t.Errorf("magnet in link should be used as magnet: %+v", second)
+	}
+	if second.Seeders != -1 {
+		t.Errorf("seeders: got %d, want -1 for a feed without them", second.Seeders)
+	}
+	if second.Date != "2025-06-02 08:30" {
+		t.Errorf("date: got %q", second.Date)
+	}
+}
+
+func TestFeedSearchAtom(t *testing.T) {
+	isolateConfig(t)
+
+	entries := searchFeed(t, atomFeed)
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2: %+v", len(entries), entries)
+	}
+
+	first := entries[0]
+	if first.TorrentLink != "https://tracker.example/download/3.torrent" || first.MagnetLink != "" {
+		t.Errorf("links wrong, only alternate and enclosure count: %+v", first)
+	}
+	if first.Seeders != -1 || first.Size != 700*1024*1024 {
+		t.Errorf("first entry mapped wrong: %+v", first)
+	}
+	if first.Date != "2025-06-03 08:15" {
+		t.Errorf("date: got %q, want it in UTC", first.Date)
+	}
+
+	second := entries[1]
+	if second.MagnetLink != "magnet:?xt=urn:btih:3123456789abcdef0123456789abcdef01234567" || second.TorrentLink != "" {
+		t.Errorf("a link without rel should be used: %+v", second)
+	}
+	if second.Seeders != 4 || second.Date != "2025-06-04 00:00" {
+		t.Errorf("second entry mapped wrong: %+v", second)
+	}
+}
+
+func TestSetFeedLink(t *testing.T) {
+	var r release
+	setFeedLink(&r, "https://tracker.example/view/1")
+	setFeedLink(&r, "https://tracker.example/download/1.TORRENT")
+	setFeedLink(&r, "magnet:?xt=urn:btih:first")
+	setFeedLink(&r, "https://tracker.example/download/2.torrent")
+	setFeedLink(&r, "magnet:?xt=urn:btih:second")
+
+	if r.torrentLink != "https://tracker.example/download/1.TORRENT" || r.magnetLink != "magnet:?xt=urn:btih:first" {
+		t.Errorf("want the first torrent and magnet link, page links ignored: %+v", r)
+	}
+}
+
+func TestParseFeedSeeders(t *testing.T) {
+	for input, want := range map[string]int{"12": 12, " 0 ": 0, "": -1, "n/a": -1} {
+		if got := parseFeedSeeders(input); got != want {
+			t.Errorf("%q: got %d, want %d", input, got, want)
+		}
+	}
+}
+
+func TestFormatFeedDate(t *testing.T) {
+	tests := map[string]string{
+		"Sun, 01 Jun 2025 12:00:00 +0000": "2025-06-01 12:00", // RFC 1123 numeric zone
+		"Mon, 02 Jun 2025 08:30:00 GMT":   "2025-06-02 08:30", // RFC 1123
+		"2025-06-03T10:15:00+02:00":       "2025-06-03 08:15", // RFC 3339
+		" 2025-06-04T00:00:00Z ":          "2025-06-04 00:00",
+		"yesterday":                       "yesterday", // shown as the feed has it
+	}
+
+	for input, want := range tests {
+		if got := formatFeedDate(input); got != want {
+			t.Errorf("%q: got %q, want %q", input, got, want)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+// scraper/html.go
+package scraper
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"opforjellyfin/internal/shared"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// htmlProvider scrapes a tracker's search result pages, using the CSS
+// selectors from its ScraperConfig
+type htmlProvider struct {
+	config shared.ScraperConfig
+}
+
+func (p *htmlProvider) Name() string {
+	return p.config.Name
+}
+
+// pageWorkers search pages are fetched at once. The last page is only known
+// once an empty one comes back, so a search fetches up to pageWorkers-1 pages
+// past the end - the per-host rate limit keeps that polite.
+const pageWorkers = 4
+
+// maxPages stops a tracker that never returns an empty page
+const maxPages = 200
+
+// result of fetching one search page
+type pageResult struct {
+	entries []shared.TorrentEntry
+	rows    int
+	err     error
+}
+
+// Search fetches the search pages in waves of pageWorkers, until one comes back
+// without rows. Pages that still fail after retries are skipped - the rest
+// is returned along with an error naming them.
+func (p *htmlProvider) Search(query string) ([]shared.TorrentEntry, error) {
+	var rawEntries []shared.TorrentEntry
+	var errs []error
+
+	for start := 1; start <= maxPages; start += pageWorkers {
+		results := make([]pageResult, pageWorkers)
+
+		var wg sync.WaitGroup
+		for i := range results {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				results[i] = p.fetchPage(query, start+i)
+			}(i)
+		}
+		wg.Wait()
+
+		lastPage := false
+		allFailed := true
+		for i, r := range results {
+			if r.err != nil {
+				errs = append(errs, fmt.Errorf("page %d: %w", start+i, r.err))
+				continue
+			}
+			allFailed = false
+
+			if r.rows == 0 {
+				lastPage = true // finito
+				break
+			}
+			rawEntries = append(rawEntries, r.entries...)
+		}
+
+		// a wave where nothing worked means the site is down - don't
+		// keep hammering it for maxPages
+		if lastPage || allFailed {
+			break
+		}
+	}
+
+	if len(errs) > 0 {
+		if len(rawEntries) == 0 {
+			return nil, errors.Join(errs...)
+		}
+		return rawEntries, fmt.Errorf("partial results, %d page(s) failed: %w", len(errs), errors.Join(errs...))
+	}
+
+	return rawEntries, nil
+}
+
+// fetches and parses a single search page
+func (p *htmlProvider) fetchPage(query string, page int) pageResult {
+	srcConfig := p.config
+	searchURL := fmt.Sprintf(srcConfig.BaseURL+srcConfig.SearchPathTemplate, query, page)
+
+	doc, err := fetchDoc(searchURL)
+	if err != nil {
+		return pageResult{err: err}
+	}
+
+	rows := doc.Find(srcConfig.RowSelector)
+
+	var entries []shared.TorrentEntry
+	rows.Each(func(i int, s *goquery.Selection) {
+		entry, ok := parseRow(s, &srcConfig, srcConfig.BaseURL)
+		if ok {
+			entries = append(entries, entry)
+		}
+	})
+
+	return pageResult{entries: entries, rows: rows.Length()}
+}
+
+// TorrentURL returns the scraped link if it points at a .torrent file,
+// otherwise the tracker's /download/<id>.torrent path for the site ID in it
+func (p *htmlProvider) TorrentURL(entry shared.TorrentEntry) string {
+	if strings.HasSuffix(strings.ToLower(entry.TorrentLink), ".torrent") {
+		return entry.TorrentLink
+	}
+
+	id := siteTorrentID(entry.TorrentLink, &p.config)
+	if id == 0 {
+		return entry.TorrentLink
+	}
+
+	return fmt.Sprintf("%s/download/%d.torrent", p.config.BaseURL, id)
+}
+
+// fetchDoc fetches a single search-results page and parses it into a goquery
+// document
+func fetchDoc(url string) (*goquery.Document, error) {
+	body, err := fetchBody(url)
+	if err != nil {
+		return nil, err
+	}
+
+	return goquery.NewDocumentFromReader(bytes.NewReader(body))
+}
+
+// parseRow extracts torrent data from a table row using the scraper config
+func parseRow(s *goquery.Selection, config *shared.ScraperConfig, baseURL string) (shared.TorrentEntry, bool) {
+	// Extract fields using configured selectors
+	title := s.Find(config.Fields.Title).Text()
+	seedersStr := s.Find(config.Fields.Seeders).Text()
+	torrentLink, _ := s.Find(config.Fields.TorrentLink).Attr("href")
+	magnetLink := ""
+	if config.Fields.MagnetLink != "" {
+		href, _ := s.Find(config.Fields.MagnetLink).Attr("href")
+		if strings.HasPrefix(href, "magnet:") {
+			magnetLink = href
+		}
+	}
+	date := s.Find(config.Fields.UploadDate).Text()
+	var size int64
+	if config.Fields.Size != "" {
+		size = shared.ParseSize(strings.TrimSpace(s.Find(config.Fields.Size).Text()))
+	}
+
+	if torrentLink == "" && magnetLink == "" {
+		return shared.TorrentEntry{}, false
+	}
+
+	// Make torrent link absolute if needed
+	if torrentLink != "" && !strings.HasPrefix(torrentLink, "http") {
+		torrentLink = baseURL + torrentLink
+	}
+
+	seeders, _ := strconv.Atoi(strings.TrimSpace(seedersStr))
+
+	return buildEntry(release{
+		title:       title,
+		seeders:     seeders,
+		size:        size,
+		torrentLink: torrentLink,
+		magnetLink:  magnetLink,
+		date:        date,
+	}, config)
+}
@@ -0,0 +1,172 @@
+// scraper/http.go
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"opforjellyfin/internal/logger"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// requestTimeout bounds each request, so a slow or hanging tracker doesn't
+// stall a search forever.
+const requestTimeout = 15 * time.Second
+
+// retry settings for failed requests. Vars so tests don't have to wait.
+var (
+	maxAttempts    = 4
+	retryBaseDelay = 500 * time.Millisecond // doubled after every attempt
+	maxRetryAfter  = 60 * time.Second       // cap on a server's Retry-After
+)
+
+// politeness: requests per second (and burst) allowed per host, shared by every
+// provider and page worker talking to it
+var (
+	hostRate  rate.Limit = 2
+	hostBurst            = 2
+)
+
+var (
+	hostLimiters   = make(map[string]*rate.Limiter)
+	hostLimitersMu sync.Mutex
+)
+
+// statusError is a non-200 response
+type statusError struct {
+	code       int
+	retryAfter time.Duration // from the Retry-After header on 429/503, 0 if none
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("unexpected status code: %d", e.code)
+}
+
+// fetchBody fetches url and returns the whole body. Network errors, 429 and
+// 5xx responses are retried with exponential backoff, or after the server's
+// Retry-After when it sends one.
+func fetchBody(url string) ([]byte, error) {
+	var err error
+	delay := retryBaseDelay
+
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		var body []byte
+		body, err = fetchOnce(url)
+		if err == nil {
+			return body, nil
+		}
+
+		if !retryable(err) || attempt == maxAttempts {
+			break
+		}
+
+		wait := delay
+		if se, ok := err.(*statusError); ok && se.retryAfter > 0 {
+			wait = se.retryAfter
+		}
+
+		logger.Log(false, "fetch %s failed (attempt %d/%d): %v - retrying in %s", url, attempt, maxAttempts, err, wait)
+		time.Sleep(wait)
+		delay *= 2
+	}
+
+	return nil, err
+}
+
+// fetchOnce does a single rate limited request with a bounded timeout. The
+// response body is closed before returning rather than deferred up to the
+// caller's loop, so it doesn't stay open across every remaining page.
+func fetchOnce(rawURL string) ([]byte, error) {
+	if err := waitForHost(rawURL); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		se := &statusError{code: resp.StatusCode}
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+			se.retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+		}
+		return nil, se
+	}
+
+	return io.ReadAll(resp.Body)
+}
+
+// blocks until the url's host may be sent another request
+func waitForHost(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+
+	hostLimitersMu.Lock()
+	limiter, ok := hostLimiters[u.Host]
+	if !ok {
+		limiter = rate.NewLimiter(hostRate, hostBurst)
+		hostLimiters[u.Host] = limiter
+	}
+	hostLimitersMu.Unlock()
+
+	return limiter.Wait(context.Background())
+}
+
+// only network errors, timeouts and 429/5xx are worth asking again. Client
+// errors, bad urls and requests that can't be built fail the same every time
+func retryable(err error) bool {
+	if se, ok := err.(*statusError); ok {
+		return se.code == http.StatusTooManyRequests || se.code >= 500
+	}
+
+	// url.Error is itself a net.Error, whatever it wraps
+	if ue, ok := err.(*url.Error); ok {
+		if ue.Timeout() {
+			return true
+		}
+		err = ue.Err
+	}
+
+	var ne net.Error
+	return errors.As(err, &ne) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET)
+}
+
+// Retry-After is either delay-seconds or an HTTP date. 0 if missing or invalid
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+
+	var wait time.Duration
+	if secs, err := strconv.Atoi(value); err == nil {
+		wait = time.Duration(secs) * time.Second
+	} else if at, err := http.ParseTime(value); err == nil {
+		wait = time.Until(at)
+	}
+
+	if wait <= 0 {
+		return 0
+	}
+	return min(wait, maxRetryAfter)
+}
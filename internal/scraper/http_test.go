@@ -0,0 +1,182 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"opforjellyfin/internal/shared"
+
+	"golang.org/x/time/rate"
+)
+
+// no real waiting between retries or requests in tests
+func fastRetries(t *testing.T) {
+	oldDelay, oldRate, oldBurst := retryBaseDelay, hostRate, hostBurst
+	retryBaseDelay, hostRate, hostBurst = time.Millisecond, rate.Inf, 1
+	t.Cleanup(func() {
+		retryBaseDelay, hostRate, hostBurst = oldDelay, oldRate, oldBurst
+	})
+}
+
+func TestFetchBodyRetriesAfterRetryAfter(t *testing.T) {
+	fastRetries(t)
+
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	body, err := fetchBody(srv.URL)
+	if err != nil || string(body) != "ok" {
+		t.Fatalf("got (%q, %v), want (\"ok\", nil)", body, err)
+	}
+	if calls.Load() != 3 {
+		t.Errorf("got %d requests, want 3", calls.Load())
+	}
+}
+
+func TestFetchBodyDoesNotRetryClientErrors(t *testing.T) {
+	fastRetries(t)
+
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	if _, err := fetchBody(srv.URL); err == nil {
+		t.Fatal("expected an error for 404")
+	}
+	if calls.Load() != 1 {
+		t.Errorf("got %d requests, want 1", calls.Load())
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	_, parseErr := url.Parse("http://[::1")
+	reset := &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"503", &statusError{code: http.StatusServiceUnavailable}, true},
+		{"429", &statusError{code: http.StatusTooManyRequests}, true},
+		{"404", &statusError{code: http.StatusNotFound}, false},
+		{"bad url", parseErr, false},
+		{"unsupported scheme", &url.Error{Op: "Get", URL: "ftp://x", Err: errors.New(`unsupported protocol scheme "ftp"`)}, false},
+		{"timeout", &url.Error{Op: "Get", URL: "http://x", Err: context.DeadlineExceeded}, true},
+		{"connection reset", &url.Error{Op: "Get", URL: "http://x", Err: reset}, true},
+		{"cut off body", io.ErrUnexpectedEOF, true},
+		{"other", errors.New("boom"), false},
+	}
+
+	for _, tc := range tests {
+		if got := retryable(tc.err); got != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestFetchBodyDoesNotRetryBadURLs(t *testing.T) {
+	oldDelay := retryBaseDelay
+	retryBaseDelay = time.Hour // a retry would hang the test
+	t.Cleanup(func() { retryBaseDelay = oldDelay })
+
+	if _, err := fetchBody("http://[::1"); err == nil {
+		t.Fatal("expected an error for a malformed url")
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		input string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"5", 5 * time.Second},
+		{"-3", 0},
+		{"3600", maxRetryAfter},
+		{"soon", 0},
+		{time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), 0},
+	}
+
+	for _, tc := range tests {
+		if got := parseRetryAfter(tc.input); got != tc.want {
+			t.Errorf("input %q: got %s, want %s", tc.input, got, tc.want)
+		}
+	}
+}
+
+func TestHTMLSearchReturnsPartialResults(t *testing.T) {
+	fastRetries(t)
+	isolateConfig(t)
+
+	// pages 1, 2 and 4 have a row each, 3 always fails, 5 onwards are empty
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page := r.URL.Query().Get("p")
+		switch page {
+		case "3":
+			w.WriteHeader(http.StatusInternalServerError)
+		case "1", "2", "4":
+			fmt.Fprintf(w, `<table><tr class="row"><td class="title">[One Pace][%s-%s] Arc [1080p]</td><td class="seeders">3</td><td><a class="dl" href="/download/%s.torrent">dl</a></td></tr></table>`, page, page, page)
+		default:
+			w.Write([]byte("<table></table>"))
+		}
+	}))
+	defer srv.Close()
+
+	p := &htmlProvider{config: shared.ScraperConfig{
+		Name:               "test",
+		BaseURL:            srv.URL,
+		SearchPathTemplate: "/?q=%s&p=%d",
+		RowSelector:        "tr.row",
+		Fields: shared.ScraperFields{
+			Title:       "td.title",
+			Seeders:     "td.seeders",
+			TorrentLink: "a.dl",
+			TorrentID:   `/download/(\d+)\.torrent`,
+		},
+	}}
+
+	entries, err := p.Search("one+pace")
+	if err == nil || !strings.Contains(err.Error(), "page 3") {
+		t.Errorf("expected an error naming page 3, got %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("got %d entries, want 3: %+v", len(entries), entries)
+	}
+	for i, want := range []int{1, 2, 4} {
+		if got := siteTorrentID(entries[i].TorrentLink, &p.config); got != want {
+			t.Errorf("entry %d: got site ID %d, want %d", i, got, want)
+		}
+	}
+
+	// the same site ID on another source is another torrent
+	other := p.config
+	other.Name, other.BaseURL = "mirror", "http://mirror.example"
+	mirrored, ok := buildEntry(release{title: "[One Pace][1-1] Arc [1080p]", torrentLink: "http://mirror.example/download/1.torrent"}, &other)
+	if !ok || mirrored.TorrentID == entries[0].TorrentID {
+		t.Errorf("torrent 1 of two sources should get different IDs, both got %d", entries[0].TorrentID)
+	}
+}
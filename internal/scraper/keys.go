@@ -0,0 +1,107 @@
+package scraper
+
+import (
+	"encoding/json"
+	"opforjellyfin/internal/shared"
+	"os"
+	"path/filepath"
+)
+
+const (
+	keyRegistryFileName = "download_keys.json"
+
+	// specials count down from here, everything else up from 1
+	firstSpecialKey = 9999
+)
+
+// keyRegistry remembers which download key each release was given, so a new
+// upload in the middle of the list doesn't shift every key after it. Point
+// several machines at the same file (key_registry in config.json) and they
+// agree on keys too.
+type keyRegistry struct {
+	path string
+	Keys map[string]int `json:"keys"` // releaseID -> key
+}
+
+// where the registry lives: key_registry from config (relative paths are
+// relative to the config dir), else the config dir itself
+func keyRegistryPath(cfg *shared.Config) string {
+	path := cfg.KeyRegistry
+	if path == "" {
+		return filepath.Join(shared.ConfigDir(), keyRegistryFileName)
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(shared.ConfigDir(), path)
+	}
+	return path
+}
+
+// loads the registry, an empty one if there is none yet. On error an empty
+// registry is still returned so keys can be handed out for this run - it
+// should not be saved over the unreadable file though.
+func loadKeyRegistry(cfg *shared.Config) (*keyRegistry, error) {
+	reg := &keyRegistry{path: keyRegistryPath(cfg), Keys: make(map[string]int)}
+
+	data, err := os.ReadFile(reg.path)
+	if os.IsNotExist(err) {
+		return reg, nil
+	}
+	if err != nil {
+		return reg, err
+	}
+
+	if err := json.Unmarshal(data, reg); err != nil {
+		reg.Keys = make(map[string]int)
+		return reg, err
+	}
+	if reg.Keys == nil {
+		reg.Keys = make(map[string]int)
+	}
+
+	return reg, nil
+}
+
+// keyFor returns the key recorded for id, or records the next free one
+func (r *keyRegistry) keyFor(id string, special bool) int {
+	if key, ok := r.Keys[id]; ok {
+		return key
+	}
+
+	key := r.nextKey(special)
+	r.Keys[id] = key
+	return key
+}
+
+// one past the highest regular key, or one below the lowest special key.
+// The upper half of the key space belongs to specials.
+func (r *keyRegistry) nextKey(special bool) int {
+	next := 1
+	if special {
+		next = firstSpecialKey
+	}
+
+	for _, key := range r.Keys {
+		if special && key > firstSpecialKey/2 && key <= next {
+			next = key - 1
+		}
+		if !special && key <= firstSpecialKey/2 && key >= next {
+			next = key + 1
+		}
+	}
+
+	return next
+}
+
+// writes the registry back, via a temp file so a shared copy is never half written
+func (r *keyRegistry) save() error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(r.path), 0755); err != nil {
+		return err
+	}
+
+	return shared.WriteFileAtomic(r.path, data, 0644)
+}
@@ -0,0 +1,56 @@
+package scraper
+
+import (
+	"testing"
+
+	"opforjellyfin/internal/shared"
+)
+
+func TestKeysSurviveNewUploads(t *testing.T) {
+	isolateConfig(t)
+	cfg := &shared.Config{}
+
+	entry := func(rng string, index int, quality string) shared.TorrentEntry {
+		return shared.TorrentEntry{ChapterRange: rng, RawIndex: index, Quality: quality, Seeders: 5}
+	}
+	special := shared.TorrentEntry{TorrentName: "Cover Stories", IsSpecial: true, Quality: "1080p", Seeders: 5}
+
+	keys, err := loadKeyRegistry(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := processEntries([]shared.TorrentEntry{
+		entry("1-7", 1, "1080p"),
+		entry("12-21", 12, "1080p"),
+		special,
+	}, keys)
+	if err := keys.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]int{"1-7|1080p": 1, "12-21|1080p": 2, "special:cover stories|1080p": 9999}
+	for _, e := range first {
+		if e.DownloadKey != want[releaseID(e)] {
+			t.Errorf("first list: %s got key %d, want %d", releaseID(e), e.DownloadKey, want[releaseID(e)])
+		}
+	}
+
+	// an upload sorting in between must not shift the keys after it
+	keys, err = loadKeyRegistry(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second := processEntries([]shared.TorrentEntry{
+		entry("1-7", 1, "1080p"),
+		entry("8-11", 8, "1080p"),
+		entry("12-21", 12, "1080p"),
+		special,
+	}, keys)
+
+	want["8-11|1080p"] = 3
+	for _, e := range second {
+		if e.DownloadKey != want[releaseID(e)] {
+			t.Errorf("second list: %s got key %d, want %d", releaseID(e), e.DownloadKey, want[releaseID(e)])
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package scraper
+
+import (
+	"fmt"
+	"opforjellyfin/internal/shared"
+)
+
+// source kinds, set in ScraperConfig.Kind. Empty means KindHTML, which is
+// what configs from before there were several kinds use.
+const (
+	KindHTML    = "html"
+	KindRSS     = "rss" // RSS or Atom feed
+	KindTorznab = "torznab"
+)
+
+// Provider is a torrent source. FetchTorrents queries every configured
+// provider and merges their results before download keys are assigned.
+type Provider interface {
+	// Name identifies the source, and is stored on its entries
+	Name() string
+	// Search returns all entries matching query. If only part of the search
+	// failed, the entries found are returned along with the error.
+	Search(query string) ([]shared.TorrentEntry, error)
+	// TorrentURL returns where to fetch the .torrent file for one of its entries
+	TorrentURL(entry shared.TorrentEntry) string
+}
+
+// NewProvider creates the provider for a source config, based on its kind
+func NewProvider(src shared.ScraperConfig) (Provider, error) {
+	switch src.Kind {
+	case "", KindHTML:
+		if src.BaseURL == "" {
+			return nil, fmt.Errorf("source %q has no base_url", src.Name)
+		}
+		return &htmlProvider{config: src}, nil
+	case KindRSS:
+		if src.BaseURL == "" {
+			return nil, fmt.Errorf("source %q has no base_url", src.Name)
+		}
+		return &feedProvider{config: src}, nil
+	case KindTorznab:
+		if src.APIURL == "" {
+			return nil, fmt.Errorf("source %q has no api_url", src.Name)
+		}
+		return &torznabProvider{config: src}, nil
+	default:
+		return nil, fmt.Errorf("source %q has unknown kind %q", src.Name, src.Kind)
+	}
+}
+
+// TorrentURL returns the .torrent URL for entry, asking the provider of the
+// source it was found on.
+func TorrentURL(cfg *shared.Config, entry shared.TorrentEntry) string {
+	sources := cfg.AllSources()
+	if len(sources) == 0 {
+		return entry.TorrentLink
+	}
+
+	src := sources[0]
+	for _, s := range sources {
+		if s.Name == entry.Source {
+			src = s
+			break
+		}
+	}
+
+	p, err := NewProvider(src)
+	if err != nil {
+		return entry.TorrentLink
+	}
+
+	return p.TorrentURL(entry)
+}
@@ -2,9 +2,11 @@
 package scraper
 
 import (
-	"context"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
 	"fmt"
-	"net/http"
+	"net/url"
 	"opforjellyfin/internal/logger"
 	"opforjellyfin/internal/metadata"
 	"opforjellyfin/internal/shared"
@@ -12,155 +14,266 @@ import (
 	"sort"
 	"strconv"
 	"strings"
-	"time"
+	"sync"
 
-	"github.com/PuerkitoBio/goquery"
+	"github.com/anacrolix/torrent/metainfo"
 )
 
-// TODO: sort file, add more structs, add scrape-map
-
-// requestTimeout bounds each per-page search request, so a slow or hanging
-// tracker doesn't stall FetchTorrents' unbounded pagination loop forever.
-const requestTimeout = 15 * time.Second
+// release is what a provider extracts for one torrent, before buildEntry
+// derives the One Pace specific fields from its title
+type release struct {
+	title       string
+	seeders     int // -1 if the source doesn't tell
+	size        int64
+	torrentLink string // absolute
+	magnetLink  string
+	infoHash    string
+	date        string
+}
 
-// gets the torrents using current config, throws error if no valid config found
+// gets the torrents from every configured source, throws error if no valid
+// config found. Sources are searched concurrently - if some of them fail, the
+// results of the others are still returned, along with an error naming the
+// failed ones. Only when nothing was found at all are the results nil.
 func FetchTorrents(cfg *shared.Config) ([]shared.TorrentEntry, error) {
-	//prep
-
-	// use scraper config from main config
-	srcConfig := cfg.Source
-
-	// ensure we have a valid scraper config
-	if srcConfig.Name == "" || srcConfig.BaseURL == "" {
+	sources := cfg.AllSources()
+	if len(sources) == 0 {
 		return nil, fmt.Errorf("no scraper configuration found. Please run 'opfor setDir <path>' first")
 	}
 
-	baseURL := srcConfig.BaseURL
+	var (
+		wg         sync.WaitGroup
+		resultsMu  sync.Mutex
+		rawEntries []shared.TorrentEntry
+		errs       []error
+	)
 
-	var rawEntries []shared.TorrentEntry
-
-	page := 1
-	for {
-		searchURL := fmt.Sprintf(baseURL+srcConfig.SearchPathTemplate, srcConfig.SearchQuery, page)
-
-		doc, err := fetchDoc(searchURL)
+	for _, src := range sources {
+		p, err := NewProvider(src)
 		if err != nil {
-			return nil, err
+			errs = append(errs, err)
+			continue
 		}
 
-		rows := doc.Find(srcConfig.RowSelector)
-		if rows.Length() == 0 {
-			break // finito
-		}
+		wg.Add(1)
+		go func(p Provider, query string) {
+			defer wg.Done()
+
+			entries, err := p.Search(query)
 
-		rows.Each(func(i int, s *goquery.Selection) {
-			entry, ok := parseRow(s, &srcConfig, baseURL)
-			if ok {
-				rawEntries = append(rawEntries, entry)
+			resultsMu.Lock()
+			defer resultsMu.Unlock()
+
+			if err != nil {
+				logger.Log(false, "source %s failed: %v", p.Name(), err)
+				errs = append(errs, fmt.Errorf("%s: %w", p.Name(), err))
 			}
-		})
 
-		page++
+			for i := range entries {
+				entries[i].Source = p.Name()
+			}
+			rawEntries = append(rawEntries, entries...)
+		}(p, src.SearchQuery)
 	}
 
-	// Sort and assign download keys
-	return processEntries(rawEntries), nil
-}
+	wg.Wait()
 
-// fetchDoc fetches a single search-results page with a bounded timeout and
-// parses it into a goquery document. The response body is closed before
-// returning rather than deferred up to the caller's loop, so it doesn't stay
-// open across every remaining page of pagination.
-func fetchDoc(url string) (*goquery.Document, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
-	defer cancel()
+	if len(rawEntries) == 0 && len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	// keys already handed out, so they stay put between searches
+	keys, err := loadKeyRegistry(cfg)
 	if err != nil {
-		return nil, err
+		logger.Log(true, "⚠️ Could not read download key registry, keys may differ from earlier lists: %v", err)
 	}
 
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, err
+	// Merge, sort and assign download keys
+	entries := processEntries(mergeEntries(rawEntries), keys)
+
+	if err == nil {
+		if err := keys.save(); err != nil {
+			logger.Log(true, "⚠️ Could not save download key registry: %v", err)
+		}
+	}
+
+	// save to cache
+	if err := SaveSearchCache(cfg, entries); err != nil {
+		logger.Log(false, "Failed to cache: %v", err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	return entries, errors.Join(errs...)
+}
+
+// mergeEntries drops duplicates of the same release found on several sources,
+// keeping the newest version, then the best seeded one. Releases are told apart by chapter range and
+// quality - by range alone the 720p and 1080p uploads of an arc would collapse
+// into one. Specials have no range and go by name instead.
+func mergeEntries(entries []shared.TorrentEntry) []shared.TorrentEntry {
+	best := make(map[string]int)
+	var merged []shared.TorrentEntry
+
+	for _, e := range entries {
+		id := releaseID(e)
+
+		if i, seen := best[id]; seen {
+			if e.Version > merged[i].Version || (e.Version == merged[i].Version && e.Seeders > merged[i].Seeders) {
+				merged[i] = e
+			}
+			continue
+		}
+
+		best[id] = len(merged)
+		merged = append(merged, e)
 	}
 
-	return goquery.NewDocumentFromReader(resp.Body)
+	return merged
 }
 
-// parseRow extracts torrent data from a table row using the scraper config
-func parseRow(s *goquery.Selection, config *shared.ScraperConfig, baseURL string) (shared.TorrentEntry, bool) {
-	// Extract fields using configured selectors
-	title := s.Find(config.Fields.Title).Text()
-	seedersStr := s.Find(config.Fields.Seeders).Text()
-	torrentLink, _ := s.Find(config.Fields.TorrentLink).Attr("href")
-	date := s.Find(config.Fields.UploadDate).Text()
+// releaseID identifies a release across sources and searches: chapter range
+// and quality, or name and quality for specials. Extended cuts are releases of
+// their own, re-released versions (v2..) are not.
+func releaseID(e shared.TorrentEntry) string {
+	id := e.ChapterRange
+	if id == "" {
+		id = "special:" + strings.ToLower(e.TorrentName)
+	}
+	id += "|" + e.Quality
+	if e.Extended {
+		id += "|extended"
+	}
+	return id
+}
 
+// buildEntry validates a release against the source config and turns it into
+// a TorrentEntry. Returns false if the release should be skipped.
+func buildEntry(r release, config *shared.ScraperConfig) (shared.TorrentEntry, bool) {
 	// Validate based on config
 	if config.Validation.RequiredInTitle != "" {
-		if !strings.Contains(strings.ToLower(title), strings.ToLower(config.Validation.RequiredInTitle)) {
+		if !strings.Contains(strings.ToLower(r.title), strings.ToLower(config.Validation.RequiredInTitle)) {
 			return shared.TorrentEntry{}, false
 		}
 	}
 
-	if torrentLink == "" {
+	// only an infohash - DHT can find the peers
+	if r.magnetLink == "" && r.torrentLink == "" && r.infoHash != "" {
+		r.magnetLink = fmt.Sprintf("magnet:?xt=urn:btih:%s&dn=%s", r.infoHash, url.QueryEscape(r.title))
+	}
+
+	if r.torrentLink == "" && r.magnetLink == "" {
 		return shared.TorrentEntry{}, false
 	}
 
-	// Extract torrent ID using regex from config
+	// site IDs are only unique per site, so IDs are derived from the torrent
+	// instead - the same on every source, and never two sources' torrent 123
 	torrentID := 0
-	if config.Fields.TorrentID != "" {
-		re := regexp.MustCompile(config.Fields.TorrentID)
-		matches := re.FindStringSubmatch(torrentLink)
-		if len(matches) >= 2 {
-			torrentID, _ = strconv.Atoi(matches[1])
-		}
+	if r.infoHash != "" {
+		torrentID = torrentIDFromInfoHash(r.infoHash)
+	}
+	if torrentID == 0 && r.magnetLink != "" {
+		torrentID = torrentIDFromMagnet(r.magnetLink)
+	}
+	// nothing to derive it from, e.g. a magnet that doesn't parse - the link
+	// still tells torrents apart, 0 would give them all the same temp dir
+	if torrentID == 0 {
+		torrentID = torrentIDFromLink(r.torrentLink + r.magnetLink)
 	}
 
 	// Parse the rest of the data
-	chapterRange := shared.ExtractChapterRangeFromTitle(title)
+	chapterRange := shared.ExtractChapterRangeFromTitle(r.title)
 	rawIndex := extractRawIndex(chapterRange)
-	seeders, _ := strconv.Atoi(strings.TrimSpace(seedersStr))
-	quality := parseQuality(title)
-	torrentName := extractTorrentName(title)
-
-	// Make torrent link absolute if needed
-	if !strings.HasPrefix(torrentLink, "http") {
-		torrentLink = baseURL + torrentLink
+	quality := shared.ParseQuality(r.title)
+	version, extended := shared.ExtractReleaseVersion(r.title)
+	if version == 0 {
+		version = 1
 	}
+	torrentName := extractTorrentName(r.title)
 
 	metaDataAvail := metadata.HaveMetadata(chapterRange)
 
 	videoStatus := metadata.HaveVideoStatus(chapterRange)
 
 	return shared.TorrentEntry{
-		Title:         title,
+		Title:         r.title,
 		Quality:       quality,
 		TorrentName:   torrentName,
-		Seeders:       seeders,
+		Seeders:       r.seeders,
+		Size:          r.size,
 		RawIndex:      rawIndex,
-		TorrentLink:   torrentLink,
+		TorrentLink:   r.torrentLink,
+		MagnetLink:    r.magnetLink,
+		InfoHash:      r.infoHash,
 		TorrentID:     torrentID,
 		ChapterRange:  chapterRange,
+		Version:       version,
+		Extended:      extended,
 		IsSpecial:     chapterRange == "",
 		MetaDataAvail: metaDataAvail,
 		HaveIt:        videoStatus,
-		Date:          date,
+		Date:          r.date,
 	}, true
 }
 
-// processEntries sorts entries, filters out dead torrents and assigns unique download keys.
-func processEntries(rawEntries []shared.TorrentEntry) []shared.TorrentEntry {
+// siteTorrentID extracts the site's own ID from link with the config's regex,
+// 0 if there is none
+func siteTorrentID(link string, config *shared.ScraperConfig) int {
+	if config.Fields.TorrentID == "" {
+		return 0
+	}
+
+	re := regexp.MustCompile(config.Fields.TorrentID)
+	matches := re.FindStringSubmatch(link)
+	if len(matches) < 2 {
+		return 0
+	}
+
+	id, _ := strconv.Atoi(matches[1])
+	return id
+}
+
+// derives a positive torrent ID from a magnet's infohash, 0 if it has none
+func torrentIDFromMagnet(magnet string) int {
+	m, err := metainfo.ParseMagnetUri(magnet)
+	if err != nil {
+		logger.Log(false, "could not parse magnet %s: %v", magnet, err)
+		return 0
+	}
+
+	return torrentIDFromHash(m.InfoHash)
+}
+
+// same as torrentIDFromMagnet, for a hex infohash
+func torrentIDFromInfoHash(infoHash string) int {
+	var h metainfo.Hash
+	if err := h.FromHexString(infoHash); err != nil {
+		logger.Log(false, "could not parse infohash %s: %v", infoHash, err)
+		return 0
+	}
+
+	return torrentIDFromHash(h)
+}
+
+// same as torrentIDFromMagnet, for any link
+func torrentIDFromLink(link string) int {
+	return torrentIDFromHash(sha1.Sum([]byte(link)))
+}
+
+func torrentIDFromHash(h metainfo.Hash) int {
+	if id := int(binary.BigEndian.Uint32(h[:4]) & 0x7fffffff); id != 0 {
+		return id
+	}
+	return 1
+}
+
+// processEntries sorts entries, filters out dead torrents and assigns download
+// keys. A release keeps the key it got the first time it was listed; new ones
+// get the next free key (specials count down from 9999).
+func processEntries(rawEntries []shared.TorrentEntry, keys *keyRegistry) []shared.TorrentEntry {
 	// filter out torrents with 0 seeders
 	filtered := make([]shared.TorrentEntry, 0, len(rawEntries))
 	for _, entry := range rawEntries {
-		// 0 seeders = ignore
-		if entry.Seeders > 0 {
+		// 0 seeders = ignore, unknown (-1) is kept
+		if entry.Seeders != 0 {
 			filtered = append(filtered, entry)
 		}
 	}
@@ -173,44 +286,15 @@ func processEntries(rawEntries []shared.TorrentEntry) []shared.TorrentEntry {
 		return filtered[i].RawIndex < filtered[j].RawIndex
 	})
 
-	// assign unique download keys
-	key := 1
-	specialKey := 9999
-
+	// assign download keys
 	for i := range filtered {
-		if filtered[i].IsSpecial || filtered[i].ChapterRange == "" {
-			filtered[i].DownloadKey = specialKey
-			specialKey--
-		} else {
-			filtered[i].DownloadKey = key
-			key++
-		}
+		special := filtered[i].IsSpecial || filtered[i].ChapterRange == ""
+		filtered[i].DownloadKey = keys.keyFor(releaseID(filtered[i]), special)
 	}
 
-	// save to cache
-	err := SaveSearchCache(filtered)
-	if err != nil {
-		logger.Log(false, "Failed to cache")
-	}
 	return filtered
 }
 
-// parseQuality returns video quality based on title string
-func parseQuality(title string) string {
-	title = strings.ToLower(title)
-
-	switch {
-	case strings.Contains(title, "1080p"):
-		return "1080p"
-	case strings.Contains(title, "720p"):
-		return "720p"
-	case strings.Contains(title, "480p"):
-		return "480p"
-	default:
-		return "n/a"
-	}
-}
-
 // extracts raw index
 func extractRawIndex(rangeStr string) int {
 	if rangeStr == "" {
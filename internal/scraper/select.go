@@ -0,0 +1,125 @@
+package scraper
+
+import (
+	"opforjellyfin/internal/shared"
+	"sort"
+)
+
+// SelectForRange picks which releases to download for chapters lo-hi: the best
+// release per chapter range, leaving out ranges already covered by a bigger
+// one (no point fetching an episode on its own next to its arc). Releases
+// within lo-hi come first; a bundle reaching past it is only picked for
+// chapters none of them cover (see Contained), and then replaces those of
+// them it covers as well.
+// quality is the preferred quality, empty means the highest available.
+func SelectForRange(entries []shared.TorrentEntry, lo, hi int, quality string) []shared.TorrentEntry {
+	best := make(map[string]shared.TorrentEntry)
+	for _, e := range entries {
+		if e.IsSpecial || e.ChapterRange == "" {
+			continue
+		}
+		cMin, cMax := shared.ParseRange(e.ChapterRange)
+		if cMin < 0 || cMax < 0 || !shared.RangesOverlap(cMin, cMax, lo, hi) {
+			continue
+		}
+		if cur, ok := best[e.ChapterRange]; !ok || betterRelease(e, cur, quality) {
+			best[e.ChapterRange] = e
+		}
+	}
+
+	var contained, partial []shared.TorrentEntry
+	for _, e := range best {
+		if Contained(e, lo, hi) {
+			contained = append(contained, e)
+		} else {
+			partial = append(partial, e)
+		}
+	}
+
+	// biggest ranges first, so they claim their chapters before the smaller ones
+	sort.Slice(contained, func(i, j int) bool {
+		return rangeLen(contained[i]) > rangeLen(contained[j])
+	})
+	// least reaching past lo-hi first
+	sort.Slice(partial, func(i, j int) bool {
+		return rangeLen(partial[i]) < rangeLen(partial[j])
+	})
+
+	var selected []shared.TorrentEntry
+	for _, e := range append(contained, partial...) {
+		cMin, cMax := shared.ParseRange(e.ChapterRange)
+		if !covered(selected, max(cMin, lo), min(cMax, hi)) {
+			selected = append(selected, e)
+		}
+	}
+
+	// a bundle picked for a gap downloads the chapters around it too - what
+	// the others cover between them goes, in the order picked
+	for i := 0; i < len(selected); {
+		others := append(append([]shared.TorrentEntry{}, selected[:i]...), selected[i+1:]...)
+		cMin, cMax := shared.ParseRange(selected[i].ChapterRange)
+		if covered(others, max(cMin, lo), min(cMax, hi)) {
+			selected = others
+			continue
+		}
+		i++
+	}
+
+	sort.Slice(selected, func(i, j int) bool {
+		a, _ := shared.ParseRange(selected[i].ChapterRange)
+		b, _ := shared.ParseRange(selected[j].ChapterRange)
+		return a < b
+	})
+
+	return selected
+}
+
+// Contained reports whether all of e's chapters lie within lo-hi
+func Contained(e shared.TorrentEntry, lo, hi int) bool {
+	cMin, cMax := shared.ParseRange(e.ChapterRange)
+	return lo <= cMin && cMax <= hi
+}
+
+// betterRelease reports whether a should be picked over b: the preferred
+// quality first, then higher quality, then newer version, then more seeders
+func betterRelease(a, b shared.TorrentEntry, quality string) bool {
+	if quality != "" && (a.Quality == quality) != (b.Quality == quality) {
+		return a.Quality == quality
+	}
+	if qa, qb := shared.QualityValue(a.Quality), shared.QualityValue(b.Quality); qa != qb {
+		return qa > qb
+	}
+	if a.Version != b.Version {
+		return a.Version > b.Version
+	}
+	return a.Seeders > b.Seeders
+}
+
+func rangeLen(e shared.TorrentEntry) int {
+	cMin, cMax := shared.ParseRange(e.ChapterRange)
+	return cMax - cMin
+}
+
+// whether chapters from-to are all covered by the selected releases together
+func covered(selected []shared.TorrentEntry, from, to int) bool {
+	ranges := make([][2]int, 0, len(selected))
+	for _, s := range selected {
+		sMin, sMax := shared.ParseRange(s.ChapterRange)
+		ranges = append(ranges, [2]int{sMin, sMax})
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i][0] < ranges[j][0] })
+
+	next := from // first chapter not covered yet
+	for _, r := range ranges {
+		if r[0] > next {
+			break
+		}
+		if r[1] >= next {
+			next = r[1] + 1
+		}
+		if next > to {
+			return true
+		}
+	}
+	return next > to
+}
@@ -0,0 +1,51 @@
+package scraper
+
+import (
+	"testing"
+
+	"opforjellyfin/internal/shared"
+)
+
+func TestSelectForRange(t *testing.T) {
+	entries := []shared.TorrentEntry{
+		{DownloadKey: 1, ChapterRange: "909-1057", Quality: "720p", Seeders: 40},
+		{DownloadKey: 2, ChapterRange: "909-1057", Quality: "1080p", Seeders: 10},
+		{DownloadKey: 3, ChapterRange: "950-950", Quality: "1080p", Seeders: 99}, // inside the arc
+		{DownloadKey: 4, ChapterRange: "890-908", Quality: "1080p", Seeders: 5},
+		{DownloadKey: 5, ChapterRange: "1-7", Quality: "1080p", Seeders: 50}, // outside
+		{DownloadKey: 9999, TorrentName: "Special", IsSpecial: true, Seeders: 50},
+	}
+
+	tests := []struct {
+		lo, hi  int
+		quality string
+		want    []int
+	}{
+		// bundles for the chapters around the episode within the range,
+		// which they download as well
+		{900, 1000, "", []int{4, 2}},
+		{900, 1000, "720p", []int{4, 1}},
+		// covered by what's within, the bundles reaching past aren't needed
+		{909, 1057, "", []int{2}},
+		{950, 950, "", []int{3}},
+		{940, 960, "", []int{2}},
+	}
+
+	for _, tc := range tests {
+		got := SelectForRange(entries, tc.lo, tc.hi, tc.quality)
+		var keys []int
+		for _, e := range got {
+			keys = append(keys, e.DownloadKey)
+		}
+		if len(keys) != len(tc.want) {
+			t.Errorf("%d-%d quality %q: got keys %v, want %v", tc.lo, tc.hi, tc.quality, keys, tc.want)
+			continue
+		}
+		for i := range keys {
+			if keys[i] != tc.want[i] {
+				t.Errorf("%d-%d quality %q: got keys %v, want %v", tc.lo, tc.hi, tc.quality, keys, tc.want)
+				break
+			}
+		}
+	}
+}
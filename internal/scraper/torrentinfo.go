@@ -0,0 +1,64 @@
+package scraper
+
+import (
+	"bytes"
+	"opforjellyfin/internal/logger"
+	"opforjellyfin/internal/shared"
+	"sync"
+
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+// ResolveSizes fills in the size of entries whose source didn't list one, by
+// fetching their .torrent files. Magnet-only entries stay unknown (0) - their
+// size only comes with the torrent info from peers.
+func ResolveSizes(cfg *shared.Config, entries []shared.TorrentEntry) {
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, pageWorkers)
+
+	for i := range entries {
+		if entries[i].Size > 0 {
+			continue
+		}
+		url := TorrentURL(cfg, entries[i])
+		if url == "" {
+			continue
+		}
+
+		wg.Add(1)
+		go func(e *shared.TorrentEntry, url string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			size, err := torrentSize(url)
+			if err != nil {
+				logger.Log(false, "could not get size of %s: %v", e.TorrentName, err)
+				return
+			}
+			e.Size = size
+		}(&entries[i], url)
+	}
+
+	wg.Wait()
+}
+
+// total size of the files in the .torrent at url
+func torrentSize(url string) (int64, error) {
+	body, err := fetchBody(url)
+	if err != nil {
+		return 0, err
+	}
+
+	meta, err := metainfo.Load(bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	info, err := meta.UnmarshalInfo()
+	if err != nil {
+		return 0, err
+	}
+
+	return info.TotalLength(), nil
+}
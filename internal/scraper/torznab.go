@@ -0,0 +1,179 @@
+// scraper/torznab.go
+package scraper
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"opforjellyfin/internal/shared"
+	"strconv"
+	"strings"
+)
+
+// torznabProvider searches a Torznab indexer (Jackett, Prowlarr, ...) through
+// its standard XML API, configured with APIURL and APIKey instead of selectors.
+type torznabProvider struct {
+	config shared.ScraperConfig
+}
+
+// a Torznab response is an RSS feed, or an <error> element when the request
+// was rejected (e.g. a bad API key)
+type torznabFeed struct {
+	XMLName     xml.Name
+	Items       []torznabItem `xml:"channel>item"`
+	Code        string        `xml:"code,attr"`
+	Description string        `xml:"description,attr"`
+}
+
+type torznabItem struct {
+	Title     string `xml:"title"`
+	Link      string `xml:"link"`
+	PubDate   string `xml:"pubDate"`
+	Size      int64  `xml:"size"`
+	Enclosure struct {
+		URL    string `xml:"url,attr"`
+		Length int64  `xml:"length,attr"`
+	} `xml:"enclosure"`
+	Attrs []struct {
+		Name  string `xml:"name,attr"`
+		Value string `xml:"value,attr"`
+	} `xml:"attr"` // torznab:attr
+}
+
+func (p *torznabProvider) Name() string {
+	return p.config.Name
+}
+
+// Search runs a t=search query against the indexer
+func (p *torznabProvider) Search(query string) ([]shared.TorrentEntry, error) {
+	searchURL, err := p.searchURL(query)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := fetchBody(searchURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var f torznabFeed
+	if err := xml.Unmarshal(body, &f); err != nil {
+		return nil, fmt.Errorf("could not parse torznab response: %w", err)
+	}
+
+	if f.XMLName.Local == "error" {
+		return nil, fmt.Errorf("indexer error %s: %s", f.Code, f.Description)
+	}
+
+	var entries []shared.TorrentEntry
+	for _, item := range f.Items {
+		r := item.release()
+		// entries end up in the search cache, the key stays in the config
+		r.torrentLink = withAPIKey(r.torrentLink, "")
+		if entry, ok := buildEntry(r, &p.config); ok {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries, nil
+}
+
+// TorrentURL returns the indexer's download link. These are proxied through
+// the indexer and usually don't end in .torrent, so they're used as-is, with
+// the API key Search stripped put back.
+func (p *torznabProvider) TorrentURL(entry shared.TorrentEntry) string {
+	return withAPIKey(entry.TorrentLink, p.config.APIKey)
+}
+
+// query params indexers put their API key in on download links
+var apiKeyParams = map[string]bool{"apikey": true, "jackett_apikey": true}
+
+// withAPIKey sets the API key params of link to key, "" strips the key. The
+// rest of the query is left exactly as the indexer wrote it
+func withAPIKey(link, key string) string {
+	u, err := url.Parse(link)
+	if err != nil || u.RawQuery == "" {
+		return link
+	}
+
+	params := strings.Split(u.RawQuery, "&")
+	for i, param := range params {
+		name, _, _ := strings.Cut(param, "=")
+		if apiKeyParams[strings.ToLower(name)] {
+			params[i] = name + "=" + url.QueryEscape(key)
+		}
+	}
+	u.RawQuery = strings.Join(params, "&")
+
+	return u.String()
+}
+
+// builds the API request. The query is unescaped first, since search_query is
+// usually kept pre-escaped for the html sources' path templates.
+func (p *torznabProvider) searchURL(query string) (string, error) {
+	u, err := url.Parse(p.config.APIURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid api_url for %s: %w", p.config.Name, err)
+	}
+
+	if unescaped, err := url.QueryUnescape(query); err == nil {
+		query = unescaped
+	}
+
+	params := u.Query()
+	params.Set("t", "search")
+	params.Set("q", query)
+	if p.config.APIKey != "" {
+		params.Set("apikey", p.config.APIKey)
+	}
+	if p.config.Categories != "" {
+		params.Set("cat", p.config.Categories)
+	}
+	u.RawQuery = params.Encode()
+
+	return u.String(), nil
+}
+
+func (item torznabItem) release() release {
+	r := release{
+		title:       strings.TrimSpace(item.Title),
+		seeders:     -1,
+		size:        item.Size,
+		torrentLink: strings.TrimSpace(item.Enclosure.URL),
+		date:        formatFeedDate(item.PubDate),
+	}
+
+	if r.torrentLink == "" {
+		r.torrentLink = strings.TrimSpace(item.Link)
+	}
+	if r.size == 0 {
+		r.size = item.Enclosure.Length
+	}
+
+	for _, attr := range item.Attrs {
+		switch strings.ToLower(attr.Name) {
+		case "seeders":
+			if n, err := strconv.Atoi(attr.Value); err == nil {
+				r.seeders = n
+			}
+		case "size":
+			if n, err := strconv.ParseInt(attr.Value, 10, 64); err == nil && r.size == 0 {
+				r.size = n
+			}
+		case "infohash":
+			r.infoHash = attr.Value
+		case "magneturl":
+			r.magnetLink = attr.Value
+		}
+	}
+
+	// some indexers only give a magnet, in link
+	if strings.HasPrefix(r.torrentLink, "magnet:") {
+		if r.magnetLink == "" {
+			r.magnetLink = r.torrentLink
+		}
+		r.torrentLink = ""
+	}
+
+	return r
+}
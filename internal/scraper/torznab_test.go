@@ -0,0 +1,130 @@
+package scraper
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"opforjellyfin/internal/shared"
+)
+
+const torznabResponse = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:atom="http://www.w3.org/2005/Atom" xmlns:torznab="http://torznab.com/schemas/2015/feed">
+  <channel>
+    <title>Indexer</title>
+    <item>
+      <title>[One Pace][1-7] Romance Dawn [1080p]</title>
+      <guid>https://tracker.example/view/1</guid>
+      <link>http://indexer.example/dl/1?jackett_apikey=secret&amp;file=romance</link>
+      <pubDate>Sun, 01 Jun 2025 12:00:00 +0000</pubDate>
+      <size>1288490188</size>
+      <enclosure url="http://indexer.example/dl/1?jackett_apikey=secret&amp;file=romance" length="1288490188" type="application/x-bittorrent" />
+      <torznab:attr name="seeders" value="12" />
+      <torznab:attr name="infohash" value="0123456789abcdef0123456789abcdef01234567" />
+    </item>
+    <item>
+      <title>[One Pace][8-11] Orange Town [720p]</title>
+      <link>magnet:?xt=urn:btih:1123456789abcdef0123456789abcdef01234567</link>
+      <pubDate>Mon, 02 Jun 2025 08:30:00 +0000</pubDate>
+      <torznab:attr name="seeders" value="0" />
+      <torznab:attr name="size" value="734003200" />
+    </item>
+    <item>
+      <title>[One Pace][12-17] Syrup Village [1080p]</title>
+      <link>magnet:?xt=urn:btih:not-a-hash</link>
+      <torznab:attr name="seeders" value="3" />
+    </item>
+    <item>
+      <title>Some Other Show [1080p]</title>
+      <link>http://indexer.example/dl/3</link>
+      <torznab:attr name="seeders" value="99" />
+    </item>
+  </channel>
+</rss>`
+
+// metadata lookups in buildEntry read the user's config - keep them away from it
+func isolateConfig(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+}
+
+func TestTorznabSearch(t *testing.T) {
+	isolateConfig(t)
+
+	var gotQuery map[string][]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotQuery = r.URL.Query()
+		w.Write([]byte(torznabResponse))
+	}))
+	defer srv.Close()
+
+	p := &torznabProvider{config: shared.ScraperConfig{
+		Name:       "jackett",
+		Kind:       KindTorznab,
+		APIURL:     srv.URL + "/api/v2.0/indexers/all/results/torznab/api",
+		APIKey:     "secret",
+		Categories: "5070",
+		Validation: shared.ValidationConfig{RequiredInTitle: "one pace"},
+	}}
+
+	entries, err := p.Search("one+pace")
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+
+	for key, want := range map[string]string{"t": "search", "q": "one pace", "apikey": "secret", "cat": "5070"} {
+		if got := gotQuery[key]; len(got) != 1 || got[0] != want {
+			t.Errorf("query param %s: got %v, want %q", key, got, want)
+		}
+	}
+
+	if len(entries) != 3 {
+		t.Fatalf("got %d entries, want 3 (non One Pace item filtered): %+v", len(entries), entries)
+	}
+
+	first := entries[0]
+	if first.ChapterRange != "1-7" || first.Quality != "1080p" || first.Seeders != 12 || first.Size != 1288490188 {
+		t.Errorf("first entry mapped wrong: %+v", first)
+	}
+	if first.TorrentLink != "http://indexer.example/dl/1?jackett_apikey=&file=romance" || first.InfoHash != "0123456789abcdef0123456789abcdef01234567" {
+		t.Errorf("first entry links wrong: %+v", first)
+	}
+	if first.Date != "2025-06-01 12:00" {
+		t.Errorf("first entry date: got %q", first.Date)
+	}
+	if first.TorrentID == 0 {
+		t.Errorf("first entry should get a torrent ID from its infohash")
+	}
+
+	second := entries[1]
+	if second.MagnetLink == "" || second.TorrentLink != "" {
+		t.Errorf("magnet in link should be used as magnet: %+v", second)
+	}
+	if second.Seeders != 0 || second.Size != 734003200 {
+		t.Errorf("second entry mapped wrong: %+v", second)
+	}
+	if got := p.TorrentURL(first); got != "http://indexer.example/dl/1?jackett_apikey=secret&file=romance" {
+		t.Errorf("TorrentURL should return the indexer link with its key back, got %q", got)
+	}
+
+	// the magnet has no infohash to go by, the link is all there is
+	third := entries[2]
+	if third.TorrentID == 0 || third.TorrentID == first.TorrentID || third.TorrentID == second.TorrentID {
+		t.Errorf("entry with an unparsable magnet should get an ID of its own, got %d", third.TorrentID)
+	}
+}
+
+func TestTorznabError(t *testing.T) {
+	isolateConfig(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?><error code="100" description="Invalid API Key" />`))
+	}))
+	defer srv.Close()
+
+	p := &torznabProvider{config: shared.ScraperConfig{Name: "jackett", APIURL: srv.URL}}
+
+	if _, err := p.Search("one pace"); err == nil {
+		t.Fatal("expected an error for an <error> response")
+	}
+}
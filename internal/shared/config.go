@@ -54,7 +54,7 @@ func SaveConfig(cfg Config) error {
 		return err
 	}
 
-	if err := os.WriteFile(path, data, 0644); err != nil {
+	if err := WriteFileAtomic(path, data, 0644); err != nil {
 		return err
 	}
 
@@ -63,6 +63,18 @@ func SaveConfig(cfg Config) error {
 	return nil
 }
 
+// AllSources returns every configured torrent source: the one synced from the
+// metadata repo first, then the extra ones. Unnamed (unset) sources are skipped.
+func (c *Config) AllSources() []ScraperConfig {
+	var sources []ScraperConfig
+	for _, src := range append([]ScraperConfig{c.Source}, c.Sources...) {
+		if src.Name != "" {
+			sources = append(sources, src)
+		}
+	}
+	return sources
+}
+
 // creates default config if no config-file is found
 func EnsureConfigExists() string {
 	path := getConfigPath()
@@ -3,12 +3,24 @@
 package shared
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
+	"time"
+)
+
+const downloadStateFileName = "downloads.json"
+
+// a session publishes every PublishInterval - a state file older than
+// StaleAfter belongs to a session that died without cleaning up
+const (
+	PublishInterval = 1 * time.Second
+	StaleAfter      = 10 * time.Second
 )
 
 var (
@@ -16,24 +28,22 @@ var (
 	mu              sync.RWMutex
 )
 
+// SaveTorrentDownload stores a copy of td. Workers change their own td and
+// save it, readers only ever get copies - nothing is shared unlocked
 func SaveTorrentDownload(td *TorrentDownload) {
 	mu.Lock()
 	defer mu.Unlock()
-	existing, ok := activeDownloads[td.TorrentID]
-	if ok {
-		*existing = *td // overwrite
-	} else {
-		activeDownloads[td.TorrentID] = td
-	}
+	activeDownloads[td.TorrentID] = td.clone()
 }
 
+// GetActiveDownloads returns copies of the saved downloads
 func GetActiveDownloads() []*TorrentDownload {
 	mu.RLock()
 	defer mu.RUnlock()
 
 	var list []*TorrentDownload
 	for _, td := range activeDownloads {
-		list = append(list, td)
+		list = append(list, td.clone())
 	}
 
 	// Sort by ChapterRange, then by TorrentID as a deterministic tiebreak.
@@ -47,12 +57,66 @@ func GetActiveDownloads() []*TorrentDownload {
 	return list
 }
 
-// clear cache and remove temp download directories
+func (td *TorrentDownload) clone() *TorrentDownload {
+	c := *td
+	c.PlacementFull = slices.Clone(td.PlacementFull)
+	return &c
+}
+
+// clear cache and published state. Temp download directories are kept, so
+// interrupted downloads can be resumed - use CleanupTempDirs to remove them.
 func ClearActiveDownloads() {
 	mu.Lock()
 	defer mu.Unlock()
 	activeDownloads = make(map[int]*TorrentDownload)
-	CleanupTempDirs()
+	removeDownloadState()
+}
+
+// PublishActiveDownloads writes the current downloads to the state file in the
+// config dir. Written atomically, so a reader never sees a half-written file
+// and concurrent workers publishing at once can't interleave.
+func PublishActiveDownloads() error {
+	state := DownloadState{
+		PID:       os.Getpid(),
+		UpdatedAt: time.Now(),
+		Downloads: GetActiveDownloads(),
+	}
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return WriteFileAtomic(downloadStatePath(), data, 0644)
+}
+
+// LoadPublishedDownloads reads the state file published by a download session,
+// possibly running in another process. Returns nil and no error if there is none.
+func LoadPublishedDownloads() (*DownloadState, error) {
+	data, err := os.ReadFile(downloadStatePath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var state DownloadState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+
+	return &state, nil
+}
+
+func removeDownloadState() {
+	if err := os.Remove(downloadStatePath()); err != nil && !os.IsNotExist(err) {
+		fmt.Printf("⚠️  Failed to remove %s: %v\n", downloadStatePath(), err)
+	}
+}
+
+func downloadStatePath() string {
+	return filepath.Join(ConfigDir(), downloadStateFileName)
 }
 
 func CleanupTempDirs() error {
@@ -0,0 +1,47 @@
+package shared
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestActiveDownloadsAreCopies(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	defer ClearActiveDownloads()
+
+	td := &TorrentDownload{TorrentID: 1, Title: "Romance Dawn", PlacementFull: []string{"placed"}}
+	SaveTorrentDownload(td)
+
+	// the worker keeps changing its own td, readers only see what was saved
+	td.Progress = 100
+	got := GetActiveDownloads()
+	if len(got) != 1 || got[0].Progress != 0 {
+		t.Fatalf("unsaved change visible: %+v", got)
+	}
+
+	got[0].PlacementFull[0] = "changed by a reader"
+	if again := GetActiveDownloads(); again[0].PlacementFull[0] != "placed" {
+		t.Errorf("a reader changed the saved download: %+v", again[0])
+	}
+
+	// publishing while workers save, for go test -race
+	var wg sync.WaitGroup
+	for i := range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			own := &TorrentDownload{TorrentID: i + 2}
+			for n := range 50 {
+				own.Progress = int64(n)
+				SaveTorrentDownload(own)
+			}
+		}()
+	}
+	for range 10 {
+		if err := PublishActiveDownloads(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wg.Wait()
+}
@@ -3,6 +3,7 @@ package shared
 import (
 	"errors"
 	"fmt"
+	"hash/crc32"
 	"io"
 	"opforjellyfin/internal/logger"
 	"os"
@@ -11,10 +12,69 @@ import (
 	"sync"
 )
 
-var (
-	// Mutex for directory creation and file movement operations
-	dirMutex sync.Mutex
-)
+// pathLocks hands out one mutex per path, so work on unrelated paths (a
+// metadata sync into the library, a download creating its temp dir) doesn't
+// wait on each other. Entries are dropped once nobody holds or waits for them.
+var pathLocks = struct {
+	sync.Mutex
+	locks map[string]*pathLock
+}{locks: make(map[string]*pathLock)}
+
+type pathLock struct {
+	sync.Mutex
+	refs int
+}
+
+// lockPath locks path until the returned unlock is called
+func lockPath(path string) (unlock func()) {
+	path = lockKey(path)
+
+	pathLocks.Lock()
+	l, ok := pathLocks.locks[path]
+	if !ok {
+		l = &pathLock{}
+		pathLocks.locks[path] = l
+	}
+	l.refs++
+	pathLocks.Unlock()
+
+	l.Lock()
+
+	return func() {
+		l.Unlock()
+
+		pathLocks.Lock()
+		l.refs--
+		if l.refs == 0 {
+			delete(pathLocks.locks, path)
+		}
+		pathLocks.Unlock()
+	}
+}
+
+// LockPath is lockPath for work outside this package, e.g. a metadata sync
+// holding its staging dir while it runs
+func LockPath(path string) (unlock func()) {
+	return lockPath(path)
+}
+
+// PathLocked reports whether path is locked, or waited for, right now
+func PathLocked(path string) bool {
+	path = lockKey(path)
+
+	pathLocks.Lock()
+	defer pathLocks.Unlock()
+	_, ok := pathLocks.locks[path]
+	return ok
+}
+
+// the same path, however it's written
+func lockKey(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return filepath.Clean(path)
+}
 
 // helper for tempdir
 func GetTempDir() (string, error) {
@@ -44,15 +104,15 @@ func GetTempDir() (string, error) {
 
 // CreateTempTorrentDir safely creates a temporary directory for torrent downloads
 func CreateTempTorrentDir(torrentID int) (string, error) {
-	dirMutex.Lock()
-	defer dirMutex.Unlock()
-
 	tmpBase, err := GetTempDir()
 	if err != nil {
 		return "", err
 	}
 	tmpDir := filepath.Join(tmpBase, fmt.Sprintf("opfor-tmp-%d", torrentID))
 
+	unlock := lockPath(tmpDir)
+	defer unlock()
+
 	// Check if it already exists
 	if info, err := os.Stat(tmpDir); err == nil && info.IsDir() {
 		logger.Log(false, "Temp dir already exists: %s", tmpDir)
@@ -68,74 +128,254 @@ func CreateTempTorrentDir(torrentID int) (string, error) {
 	return tmpDir, nil
 }
 
-// SafeMoveFile moves a file safely, creates the directory if it does not exist
-// This function should be thread-safe and handle concurrent file operations
+// how videos get from the download temp dir into the library
+const (
+	PlacementMove     = "move"     // rename, or copy+remove across filesystems. The default
+	PlacementHardlink = "hardlink" // link, so the torrent can keep seeding from the temp dir
+)
+
+// SafeMoveFile moves a file safely, creates the directory if it does not exist.
+// A plain rename when src and dst share a filesystem, copy+remove otherwise.
 func SafeMoveFile(src, dst string) error {
-	// Lock for the entire move operation to ensure atomicity
-	dirMutex.Lock()
-	defer dirMutex.Unlock()
+	return PlaceFile(src, dst, false)
+}
+
+// PlaceFile puts src at dst, creating dst's directory. With keepSrc it hardlinks
+// instead of moving, so src stays where it is. Either falls back to a copy when
+// that fails, e.g. src and dst are on different filesystems or the filesystem
+// has no hardlinks. dst is only ever replaced by a complete file - copies and
+// links are made under a temporary name next to it and renamed over it.
+func PlaceFile(src, dst string, keepSrc bool) error {
+	_, err := placeFile(src, dst, "", keepSrc, false)
+	return err
+}
+
+// stageFile links (keepSrc) or renames src to part without copying. A var so
+// tests can force the copy fallback
+var stageFile = func(src, part string, keepSrc bool) error {
+	if keepSrc {
+		return os.Link(src, part)
+	}
+	return os.Rename(src, part)
+}
+
+// placeFile does the work of PlaceFile and MoveFileChecked. The file is staged
+// under a temporary name next to dst and checked against wantCRC there, so dst
+// is only replaced by a verified file. A staged link or rename is hashed once
+// if hash is set; a copy is hashed on the way, so src is read only once.
+// Returns the CRC32 if one was computed.
+func placeFile(src, dst, wantCRC string, keepSrc, hash bool) (string, error) {
+	logger.Log(false, "smf: placing %s at %s (keep source: %v)", src, dst, keepSrc)
 
-	logger.Log(false, "smf: starting move from %s to %s", src, dst)
+	unlock := lockPath(dst)
+	defer unlock()
 
-	// Ensure destination directory exists
-	dstDir := filepath.Dir(dst)
-	if err := os.MkdirAll(dstDir, 0755); err != nil {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
 		logger.Log(true, "smf: failed to create dst dir: %v", err)
-		return err
+		return "", err
+	}
+
+	part, err := tempPath(dst)
+	if err != nil {
+		return "", err
+	}
+
+	err = stageFile(src, part, keepSrc)
+	if os.IsNotExist(err) {
+		return "", err
+	}
+	if err == nil {
+		// puts src back as it was
+		undo := func() {
+			if keepSrc {
+				os.Remove(part)
+			} else if err := os.Rename(part, src); err != nil {
+				logger.Log(true, "smf: could not move %s back: %v", src, err)
+			}
+		}
+
+		got := ""
+		if hash {
+			if got, err = FileCRC32(part); err == nil {
+				err = checkCRC(got, wantCRC)
+			}
+			if err != nil {
+				undo()
+				return got, err
+			}
+		}
+
+		if err := os.Rename(part, dst); err != nil {
+			undo()
+			return "", err
+		}
+		return got, nil
 	}
 
-	logger.Log(false, "smf: copying file from %s to %s", src, dst)
-	if err := copyFileInternal(src, dst, 0644); err != nil {
+	logger.Log(false, "smf: can't link/rename (%v), copying instead", err)
+	sum, err := copyFileInternal(src, part, 0644)
+	if err != nil {
+		os.Remove(part)
 		logger.Log(true, "smf: copyFile failed: %v", err)
-		return err
+		return "", err
 	}
-	logger.Log(false, "smf: copyFile succeeded")
 
-	logger.Log(false, "smf: removing source file: %s", src)
-	if err := os.Remove(src); err != nil {
-		logger.Log(true, "smf: failed to remove src: %v", err)
-		return err
+	got := fmt.Sprintf("%08X", sum)
+	if err := checkCRC(got, wantCRC); err != nil {
+		os.Remove(part)
+		return got, err
 	}
-	logger.Log(false, "smf: source file removed")
 
+	if err := os.Rename(part, dst); err != nil {
+		os.Remove(part)
+		return "", err
+	}
+
+	if !keepSrc {
+		if err := os.Remove(src); err != nil {
+			logger.Log(true, "smf: failed to remove src: %v", err)
+			return got, err
+		}
+	}
+
+	return got, nil
+}
+
+// a free temporary name next to path, for building a file before it's
+// renamed into place
+func tempPath(path string) (string, error) {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".opfor-part-*")
+	if err != nil {
+		return "", err
+	}
+	name := f.Name()
+	f.Close()
+
+	// os.Link won't overwrite
+	return name, os.Remove(name)
+}
+
+// CRCMismatchError is returned when a file doesn't match the CRC32 in its name
+type CRCMismatchError struct {
+	Want, Got string
+}
+
+func (e *CRCMismatchError) Error() string {
+	return fmt.Sprintf("CRC32 mismatch: file has %s, name says %s", e.Got, e.Want)
+}
+
+// MoveFileChecked places src at dst like PlaceFile, computing its CRC32 on the
+// way. If wantCRC is set and doesn't match, nothing is placed (an existing file
+// at dst is not overwritten), src is kept and a *CRCMismatchError returned.
+// Returns the CRC32 of the file.
+func MoveFileChecked(src, dst, wantCRC string, keepSrc bool) (string, error) {
+	return placeFile(src, dst, wantCRC, keepSrc, true)
+}
+
+// a *CRCMismatchError if want is set and isn't got
+func checkCRC(got, want string) error {
+	if want != "" && !strings.EqualFold(got, want) {
+		return &CRCMismatchError{Want: strings.ToUpper(want), Got: got}
+	}
 	return nil
 }
 
-// copyFileInternal is the internal non-locked version for use within already locked functions
-func copyFileInternal(src, dst string, perm os.FileMode) error {
+// FileCRC32 computes the CRC32 of a file, as 8 uppercase hex digits
+func FileCRC32(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := crc32.NewIEEE()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%08X", h.Sum32()), nil
+}
+
+// copyFileInternal is the internal non-locked version for use within already
+// locked functions. Returns the CRC32 of what was copied
+func copyFileInternal(src, dst string, perm os.FileMode) (uint32, error) {
 	in, err := os.Open(src)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	defer in.Close()
 
 	out, err := os.Create(dst)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	defer out.Close()
 
-	if _, err := io.Copy(out, in); err != nil {
-		return err
+	h := crc32.NewIEEE()
+	if _, err := io.Copy(io.MultiWriter(out, h), in); err != nil {
+		return 0, err
+	}
+
+	if err := out.Chmod(perm); err != nil {
+		return 0, err
 	}
 
-	return out.Chmod(perm)
+	return h.Sum32(), out.Close()
+}
+
+// copyFileAtomic copies src to a temporary name next to dst and renames it
+// into place, so readers of dst never see a half-written file
+func copyFileAtomic(src, dst string, perm os.FileMode) (uint32, error) {
+	part, err := tempPath(dst)
+	if err != nil {
+		return 0, err
+	}
+	sum, err := copyFileInternal(src, part, perm)
+	if err != nil {
+		os.Remove(part)
+		return 0, err
+	}
+	if err := os.Rename(part, dst); err != nil {
+		os.Remove(part)
+		return 0, err
+	}
+	return sum, nil
+}
+
+// WriteFileAtomic is os.WriteFile, but path is replaced in one rename - a crash
+// or a concurrent writer leaves either the old or the new content, never a mix
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	unlock := lockPath(path)
+	defer unlock()
+
+	part, err := tempPath(path)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(part, data, perm); err != nil {
+		os.Remove(part)
+		return err
+	}
+	if err := os.Rename(part, path); err != nil {
+		os.Remove(part)
+		return err
+	}
+	return nil
 }
 
 // CopyFile copies from src to dst with permissions using io.Copy. use os.Stat for permissions or 0644
-// This is the public version that locks
+// dst is locked and replaced atomically
 func CopyFile(src, dst string, perm os.FileMode) error {
-	dirMutex.Lock()
-	defer dirMutex.Unlock()
+	unlock := lockPath(dst)
+	defer unlock()
 
-	return copyFileInternal(src, dst, perm)
+	_, err := copyFileAtomic(src, dst, perm)
+	return err
 }
 
-// CreateDirectory safely creates a directory with proper locking
+// CreateDirectory creates a directory and its parents. MkdirAll copes with
+// concurrent callers on its own, so no lock
 func CreateDirectory(path string) error {
-	dirMutex.Lock()
-	defer dirMutex.Unlock()
-
 	return os.MkdirAll(path, 0755)
 }
 
@@ -147,30 +387,25 @@ func FileExists(path string) bool {
 
 // CopyDir copies all files (overwrites)
 func CopyDir(src, dst string) error {
-	dirMutex.Lock()
-	defer dirMutex.Unlock()
+	unlock := lockPath(dst)
+	defer unlock()
 
 	return walkAndCopyInternal(src, dst, false)
 }
 
 // SyncDir copies new/changed files from src to dst
 func SyncDir(src, dst string) error {
-	dirMutex.Lock()
-	defer dirMutex.Unlock()
-
-	if err := walkAndCopyInternal(src, dst, true); err != nil {
-		return err
-	}
+	unlock := lockPath(dst)
+	defer unlock()
 
-	warnStaleMetadataFiles(src, dst)
-	return nil
+	return walkAndCopyInternal(src, dst, true)
 }
 
-// warnStaleMetadataFiles reports .nfo files present locally under dst that no
+// WarnStaleMetadataFiles reports .nfo files present locally under dst that no
 // longer exist in the upstream src tree (e.g. the metadata repo renamed or
 // removed an episode). It only reports - it never deletes anything, since dst
 // also holds user-downloaded video files that this must never touch.
-func warnStaleMetadataFiles(src, dst string) {
+func WarnStaleMetadataFiles(src, dst string) {
 	err := filepath.Walk(dst, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return nil
@@ -197,18 +432,20 @@ func warnStaleMetadataFiles(src, dst string) {
 		}
 
 		if !FileExists(filepath.Join(src, relPath)) {
-			logger.Log(true, "⚠️  %s no longer exists in the metadata repo (kept locally - review manually)", relPath)
+			logger.Log(true, "⚠️  %s no longer exists in the metadata repo (kept locally - 'opfor prune' removes it)", relPath)
 		}
 
 		return nil
 	})
 
 	if err != nil {
-		logger.Log(false, "warnStaleMetadataFiles: walk failed: %v", err)
+		logger.Log(false, "WarnStaleMetadataFiles: walk failed: %v", err)
 	}
 }
 
-// walkAndCopyInternal is the internal non-locked version
+// walkAndCopyInternal is the internal non-locked version. Each file is copied
+// atomically, so a video player or media server scanning dst mid-sync only
+// ever sees old or new files
 func walkAndCopyInternal(src, dst string, onlyIfChanged bool) error {
 	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
@@ -233,6 +470,7 @@ func walkAndCopyInternal(src, dst string, onlyIfChanged bool) error {
 			}
 		}
 
-		return copyFileInternal(path, destPath, info.Mode())
+		_, err = copyFileAtomic(path, destPath, info.Mode())
+		return err
 	})
 }
@@ -0,0 +1,143 @@
+package shared
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestMoveFileChecked(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src.mkv")
+	dst := filepath.Join(dir, "lib", "dst.mkv")
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(dst, []byte("old"), 0644)
+	os.WriteFile(src, []byte("new"), 0644)
+
+	// a mismatch must leave both files alone
+	_, err := MoveFileChecked(src, dst, "DEADBEEF", false)
+	var mismatch *CRCMismatchError
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("got %v, want a CRC mismatch", err)
+	}
+	if data, _ := os.ReadFile(dst); string(data) != "old" {
+		t.Errorf("dst was overwritten with %q", data)
+	}
+	if !FileExists(src) {
+		t.Error("src was removed")
+	}
+
+	crc, err := MoveFileChecked(src, dst, mismatch.Got, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(dst); string(data) != "new" || crc != mismatch.Got {
+		t.Errorf("got %q with crc %s after move", data, crc)
+	}
+	if FileExists(src) {
+		t.Error("src still there after move")
+	}
+}
+
+func TestMoveFileCheckedCopyFallback(t *testing.T) {
+	// as if src and dst were on different filesystems
+	old := stageFile
+	stageFile = func(string, string, bool) error { return errors.New("cross-device link") }
+	defer func() { stageFile = old }()
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src.mkv")
+	dst := filepath.Join(dir, "lib", "dst.mkv")
+	os.MkdirAll(filepath.Dir(dst), 0755)
+	os.WriteFile(dst, []byte("old"), 0644)
+	os.WriteFile(src, []byte("new"), 0644)
+
+	// the copy is checked before it replaces dst, and nothing is left behind
+	_, err := MoveFileChecked(src, dst, "DEADBEEF", false)
+	var mismatch *CRCMismatchError
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("got %v, want a CRC mismatch", err)
+	}
+	if data, _ := os.ReadFile(dst); string(data) != "old" {
+		t.Errorf("dst was overwritten with %q", data)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(dst)); len(entries) != 1 || !FileExists(src) {
+		t.Errorf("got %d files next to dst, src kept: %v", len(entries), FileExists(src))
+	}
+
+	crc, err := MoveFileChecked(src, dst, mismatch.Got, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(dst); string(data) != "new" || crc != mismatch.Got || FileExists(src) {
+		t.Errorf("got %q with crc %s, src kept: %v", data, crc, FileExists(src))
+	}
+}
+
+func TestPlaceFileKeepsSourceWhenLinking(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "tmp", "src.mkv")
+	dst := filepath.Join(dir, "lib", "dst.mkv")
+
+	os.MkdirAll(filepath.Dir(src), 0755)
+	os.WriteFile(src, []byte("seeding"), 0644)
+
+	if err := PlaceFile(src, dst, true); err != nil {
+		t.Fatal(err)
+	}
+
+	if data, _ := os.ReadFile(dst); string(data) != "seeding" {
+		t.Errorf("dst has %q", data)
+	}
+	if !FileExists(src) {
+		t.Error("src was removed, the torrent couldn't keep seeding")
+	}
+
+	// nothing left behind next to dst
+	entries, _ := os.ReadDir(filepath.Dir(dst))
+	if len(entries) != 1 {
+		t.Errorf("got %d files in the library dir, want 1", len(entries))
+	}
+}
+
+func TestWriteFileAtomicConcurrent(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state.json")
+
+	// every writer's content is the same byte repeated - a torn file would
+	// mix two of them
+	var wg sync.WaitGroup
+	for i := range 16 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			data := bytes.Repeat([]byte{'a' + byte(i)}, 64*1024)
+			if err := WriteFileAtomic(path, data, 0644); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 64*1024 || bytes.Count(data, data[:1]) != len(data) {
+		t.Errorf("file is torn: %d bytes", len(data))
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("temp files left behind: %d entries", len(entries))
+	}
+	if len(pathLocks.locks) != 0 {
+		t.Errorf("%d path locks not released", len(pathLocks.locks))
+	}
+}
@@ -130,3 +130,105 @@ func NormalizeDash(s string) string {
 	// Replace en-dash and em-dash with hyphen-minus
 	return strings.NewReplacer("–", "-", "—", "-").Replace(s)
 }
+
+// parses a human readable size into bytes, e.g "1.2 GiB" -> 1288490188. Decimal
+// units (GB) are read as binary ones, as trackers mix them freely. Returns 0 if
+// it can't be parsed.
+func ParseSize(s string) int64 {
+	re := regexp.MustCompile(`(?i)^\s*([\d.]+)\s*([KMGT]?)i?B?\s*$`)
+	matches := re.FindStringSubmatch(s)
+	if len(matches) != 3 {
+		return 0
+	}
+
+	value, err := strconv.ParseFloat(matches[1], 64)
+	if err != nil {
+		return 0
+	}
+
+	exp := 0
+	if matches[2] != "" {
+		exp = strings.Index("KMGT", strings.ToUpper(matches[2])) + 1
+	}
+	for i := 0; i < exp; i++ {
+		value *= 1024
+	}
+
+	return int64(value)
+}
+
+// ParseQuality returns video quality based on title string
+func ParseQuality(title string) string {
+	title = strings.ToLower(title)
+
+	switch {
+	case strings.Contains(title, "1080p"):
+		return "1080p"
+	case strings.Contains(title, "720p"):
+		return "720p"
+	case strings.Contains(title, "480p"):
+		return "480p"
+	default:
+		return "n/a"
+	}
+}
+
+// numeric value of a quality like "1080p", 0 if unknown. For comparing qualities
+func QualityValue(quality string) int {
+	v, err := strconv.Atoi(strings.TrimSuffix(strings.ToLower(quality), "p"))
+	if err != nil {
+		return 0
+	}
+	return v
+}
+
+var (
+	releaseVersionRe  = regexp.MustCompile(`(?i)(?:^|[^a-z0-9])v(\d{1,2})(?:[^a-z0-9]|$)`)
+	extendedReleaseRe = regexp.MustCompile(`(?i)(?:^|[^a-z])extended(?:[^a-z]|$)`)
+)
+
+// ExtractReleaseVersion finds re-release markers in a title or file name: a
+// "v2" style version (0 if there is none) and whether it's an extended cut
+func ExtractReleaseVersion(title string) (int, bool) {
+	version := 0
+	if matches := releaseVersionRe.FindStringSubmatch(title); len(matches) == 2 {
+		version, _ = strconv.Atoi(matches[1])
+	}
+
+	return version, extendedReleaseRe.MatchString(title)
+}
+
+var crc32Re = regexp.MustCompile(`\[([0-9A-Fa-f]{8})\]`)
+
+// ExtractCRC32 returns the "[A1B2C3D4]" style CRC32 in a file name, uppercased.
+// "" if it has none
+func ExtractCRC32(fileName string) string {
+	matches := crc32Re.FindAllStringSubmatch(fileName, -1)
+	if len(matches) == 0 {
+		return ""
+	}
+	return strings.ToUpper(matches[len(matches)-1][1])
+}
+
+// SeasonKey is the index key, and folder name, of the season numbered s in an
+// .nfo: "Season 1" for "1" or "01", "Specials" for season 0
+func SeasonKey(s string) string {
+	n, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil {
+		return "Season " + strings.TrimSpace(s)
+	}
+	if n == 0 {
+		return "Specials"
+	}
+	return fmt.Sprintf("Season %d", n)
+}
+
+// SeasonNumber is the number of a season key, 0 for Specials or a key that
+// isn't a numbered season
+func SeasonNumber(key string) int {
+	var n int
+	if _, err := fmt.Sscanf(key, "Season %d", &n); err != nil {
+		return 0
+	}
+	return n
+}
@@ -65,3 +65,90 @@ func TestExtractChapterRangeFromNFO(t *testing.T) {
 		}
 	}
 }
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"1.5 GiB", 1610612736},
+		{"700 MiB", 734003200},
+		{"700MB", 734003200},
+		{"12 KiB", 12288},
+		{"512 Bytes", 0},
+		{"512 B", 512},
+		{"1024", 1024},
+		{"", 0},
+		{"lots", 0},
+	}
+
+	for _, tc := range tests {
+		got := ParseSize(tc.input)
+		if got != tc.expected {
+			t.Errorf("input %q: got %d, want %d", tc.input, got, tc.expected)
+		}
+	}
+}
+
+func TestExtractReleaseVersion(t *testing.T) {
+	tests := []struct {
+		input    string
+		version  int
+		extended bool
+	}{
+		{"[One Pace][1-7] Romance Dawn [1080p]", 0, false},
+		{"[One Pace][1-7] Romance Dawn v2 [1080p]", 2, false},
+		{"[One Pace][937] Wano 24 [v3][720p][ABCD1234].mkv", 3, false},
+		{"[One Pace][1-7] Romance Dawn Extended [1080p]", 0, true},
+		{"[One Pace][1-7] Romance Dawn Extended_v2 [1080p]", 2, true},
+		{"[One Pace][8-11] Navi2 [1080p]", 0, false},
+	}
+
+	for _, tc := range tests {
+		version, extended := ExtractReleaseVersion(tc.input)
+		if version != tc.version || extended != tc.extended {
+			t.Errorf("input %q: got (%d, %v), want (%d, %v)", tc.input, version, extended, tc.version, tc.extended)
+		}
+	}
+}
+
+func TestExtractCRC32(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"[One Pace][1-7] Romance Dawn 01 [1080p][a1b2c3d4].mkv", "A1B2C3D4"},
+		{"[One Pace][1-7] Romance Dawn 01 [1080p].mkv", ""},
+		{"[One Pace][12345678] weird [DEADBEEF].mp4", "DEADBEEF"},
+		{"[One Pace][1-7] Romance Dawn 01 [1080p][XYZ12345].mkv", ""},
+	}
+
+	for _, tc := range tests {
+		if got := ExtractCRC32(tc.input); got != tc.want {
+			t.Errorf("input %q: got %q, want %q", tc.input, got, tc.want)
+		}
+	}
+}
+
+func TestSeasonKey(t *testing.T) {
+	tests := []struct {
+		input string
+		key   string
+		num   int
+	}{
+		{"1", "Season 1", 1},
+		{"01", "Season 1", 1},
+		{" 10 ", "Season 10", 10},
+		{"00", "Specials", 0},
+	}
+
+	for _, tt := range tests {
+		key := SeasonKey(tt.input)
+		if key != tt.key || SeasonNumber(key) != tt.num {
+			t.Errorf("input %q: got key %q number %d, want %q %d", tt.input, key, SeasonNumber(key), tt.key, tt.num)
+		}
+	}
+	if n := SeasonNumber("Season 01"); n != 1 {
+		t.Errorf("SeasonNumber(\"Season 01\") = %d, want 1", n)
+	}
+}
@@ -1,31 +1,70 @@
 package shared
 
+import "time"
+
 // TODO: cleanup unused properties
 
 // config file
 type Config struct {
-	TargetDir  string        `json:"target_dir"`
-	GitHubRepo string        `json:"github_base_url"`
-	Source     ScraperConfig `json:"source"`
+	TargetDir  string         `json:"target_dir"`
+	GitHubRepo string         `json:"github_base_url"`
+	Source     ScraperConfig  `json:"source"`
+	Download   DownloadConfig `json:"download"`
+
+	// extra torrent sources, searched alongside Source (which 'sync' sets
+	// from the metadata repo)
+	Sources []ScraperConfig `json:"sources,omitempty"`
+
+	// download key registry, defaults to download_keys.json in the config
+	// dir. Point it at a shared file so a team gets the same keys.
+	KeyRegistry string `json:"key_registry,omitempty"`
+
+	// how downloaded videos are put in the library: "move" (default) or
+	// "hardlink", which lets --seed keep seeding the placed files
+	Placement string `json:"placement,omitempty"`
+
+	// how metadata is fetched: "archive" (default, plain HTTP) or "git",
+	// which needs git installed
+	MetadataFetcher string `json:"metadata_fetcher,omitempty"`
+
+	// branch, tag or commit of the metadata repo to sync, empty = its
+	// default branch. Pin a commit to hold every server on the same metadata
+	MetadataRef string `json:"metadata_ref,omitempty"`
+}
+
+// download session settings. Zero values mean default/unlimited
+type DownloadConfig struct {
+	MaxConcurrent int `json:"max_concurrent"`     // torrents downloaded at once, 0 = 5
+	DownloadLimit int `json:"download_limit_kib"` // KiB/s across all torrents, 0 = unlimited
+	UploadLimit   int `json:"upload_limit_kib"`   // KiB/s across all torrents, 0 = unlimited
+	ListenPort    int `json:"listen_port"`        // fixed port for port forwarding, 0 = random
 }
 
 // scrape config
 type ScraperConfig struct {
 	Name               string           `json:"name"`
+	Kind               string           `json:"kind,omitempty"` // provider implementation, empty = html
 	BaseURL            string           `json:"base_url"`
 	SearchPathTemplate string           `json:"search_path_template"`
 	SearchQuery        string           `json:"search_query"`
 	RowSelector        string           `json:"row_selector"`
 	Fields             ScraperFields    `json:"fields"`
 	Validation         ValidationConfig `json:"validation"`
+
+	// torznab only
+	APIURL     string `json:"api_url,omitempty"`
+	APIKey     string `json:"api_key,omitempty"`
+	Categories string `json:"categories,omitempty"` // comma separated, e.g. "5070"
 }
 
 type ScraperFields struct {
 	Title       string `json:"title"`
 	Seeders     string `json:"seeders"`
 	TorrentLink string `json:"torrent_link"`
+	MagnetLink  string `json:"magnet_link,omitempty"` // optional, preferred over torrent_link when present
 	TorrentID   string `json:"torrent_id"`
 	UploadDate  string `json:"upload_date"`
+	Size        string `json:"size,omitempty"` // optional, e.g. "1.2 GiB"
 }
 
 type ValidationConfig struct {
@@ -51,16 +90,31 @@ type EpisodeData struct {
 
 // download struct
 type TorrentDownload struct {
-	Title             string   // title for display
-	FullTitle         string   // full torrent title
-	TorrentID         int      // torrentID for tempdir
-	ChapterRange      string   // Main
-	Progress          int64    // used by ui progressbar
-	TotalSize         int64    // used by ui progress bar
-	PlacementFull     []string // used to display placed messages after all placements are done
-	PlacementProgress string   //used for placement messages after download is done
-	Done              bool     // set to true when torrent is downloaded
-	Placed            bool     // set to true when files are placed, before clearing active downloads
+	Title             string   `json:"title"`              // title for display
+	FullTitle         string   `json:"full_title"`         // full torrent title
+	TorrentID         int      `json:"torrent_id"`         // torrentID for tempdir
+	ChapterRange      string   `json:"chapter_range"`      // Main
+	Quality           string   `json:"quality"`            // recorded in the library on placement
+	Version           int      `json:"version"`            // release version of the torrent, see TorrentEntry
+	Extended          bool     `json:"extended"`           // extended cut
+	Source            string   `json:"source"`             // name of the source it was found on
+	UpgradeOnly       bool     `json:"upgrade_only"`       // only replace existing videos with better ones
+	MagnetLink        string   `json:"magnet_link"`        // used instead of TorrentURL when set
+	TorrentURL        string   `json:"-"`                  // .torrent file to fetch when there is no magnet, may hold an API key
+	Progress          int64    `json:"progress"`           // used by ui progressbar
+	TotalSize         int64    `json:"total_size"`         // used by ui progress bar
+	PlacementFull     []string `json:"placement_full"`     // used to display placed messages after all placements are done
+	PlacementProgress string   `json:"placement_progress"` //used for placement messages after download is done
+	Done              bool     `json:"done"`               // set to true when torrent is downloaded
+	Placed            bool     `json:"placed"`             // set to true when files are placed, before clearing active downloads
+}
+
+// snapshot of a running download session, published to the config dir so
+// other opfor processes (e.g. 'status') can read it
+type DownloadState struct {
+	PID       int                `json:"pid"`
+	UpdatedAt time.Time          `json:"updated_at"`
+	Downloads []*TorrentDownload `json:"downloads"`
 }
 
 // entry for dl
@@ -69,11 +123,17 @@ type TorrentEntry struct {
 	Quality       string // parsed quality
 	DownloadKey   int    // download key set by rawIndex
 	TorrentName   string // for display
-	Seeders       int    // number of seeders
+	Seeders       int    // number of seeders, -1 if the source doesn't tell
+	Size          int64  // total size in bytes, 0 if unknown
 	RawIndex      int    // RawIndex is based on ChapterRange, used for placement
 	TorrentLink   string // torrent link
-	TorrentID     int    // torrent ID, extracted from link
+	MagnetLink    string // magnet uri, if the source has one
+	InfoHash      string // hex infohash, if the source has one
+	TorrentID     int    // torrent ID, derived from the infohash or link so it's unique across sources
+	Source        string // name of the source it was found on
 	ChapterRange  string // torrent chapter range
+	Version       int    // release version, 1 unless re-released as v2, v3..
+	Extended      bool   // extended cut
 	MetaDataAvail bool   // metadata matching chapter range exists
 	IsSpecial     bool   // is a special (no chapter range)
 	HaveIt        int    // video with same chapter range exists
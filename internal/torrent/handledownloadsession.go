@@ -6,6 +6,7 @@ import (
 	"opforjellyfin/internal/logger"
 	"opforjellyfin/internal/matcher"
 	"opforjellyfin/internal/metadata"
+	"opforjellyfin/internal/scraper"
 	"opforjellyfin/internal/shared"
 	"opforjellyfin/internal/ui"
 	"os"
@@ -16,13 +17,55 @@ import (
 	"time"
 )
 
+// DefaultMaxConcurrent is used when the config doesn't set max_concurrent.
+const DefaultMaxConcurrent = 5
+
 // MaxConcurrent is the number of torrents downloaded (or, with seed=true,
 // downloaded-and-seeded) at once. With seed=true, a worker never returns to
 // pick up more work until the whole session is stopped (Ctrl+C) - so at most
 // MaxConcurrent of the requested entries will ever start seeding in one run.
-const MaxConcurrent = 5
+func MaxConcurrent() int {
+	cfg, err := shared.LoadConfig()
+	if err != nil || cfg.Download.MaxConcurrent <= 0 {
+		return DefaultMaxConcurrent
+	}
+	return cfg.Download.MaxConcurrent
+}
+
+// SessionOptions tune a download session
+type SessionOptions struct {
+	Seed        bool // keep seeding finished torrents until Ctrl+C
+	UpgradeOnly bool // only replace videos already in the library with better quality ones
+	Quiet       bool // no progress bars, just a line per torrent at the end (e.g. for 'watch')
+}
+
+// HandleDownloadSession downloads and places entries, all in the mode opts
+// asks for. Returns, per entry, whether it was downloaded and placed
+func HandleDownloadSession(entries []shared.TorrentEntry, outDir string, opts SessionOptions) []bool {
+	upgradeOnly := make([]bool, len(entries))
+	for i := range upgradeOnly {
+		upgradeOnly[i] = opts.UpgradeOnly
+	}
+
+	return runSession(entries, upgradeOnly, outDir, opts)
+}
+
+// ResumeSession continues interrupted downloads, each placed the way it was
+// started - an interrupted upgrade still only replaces worse videos
+func ResumeSession(pending []Resumable, outDir string, opts SessionOptions) []bool {
+	entries := make([]shared.TorrentEntry, len(pending))
+	upgradeOnly := make([]bool, len(pending))
+	for i, p := range pending {
+		entries[i] = p.TorrentEntry
+		upgradeOnly[i] = p.UpgradeOnly || opts.UpgradeOnly
+	}
+
+	return runSession(entries, upgradeOnly, outDir, opts)
+}
 
-func HandleDownloadSession(entries []shared.TorrentEntry, outDir string, seed bool) {
+// runSession is a download session, upgradeOnly is per entry
+func runSession(entries []shared.TorrentEntry, upgradeOnly []bool, outDir string, opts SessionOptions) []bool {
+	results := make([]bool, len(entries))
 
 	// Create a context that can be cancelled with Ctrl+C
 	ctx, cancel := context.WithCancel(context.Background())
@@ -31,18 +74,37 @@ func HandleDownloadSession(entries []shared.TorrentEntry, outDir string, seed bo
 	// Handle Ctrl+C
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigChan)
 	go func() {
-		<-sigChan
-		logger.Log(true, "\n❌ Received interrupt signal, cancelling downloads...")
-		cancel()
+		select {
+		case <-sigChan:
+			logger.Log(true, "\n❌ Received interrupt signal, cancelling downloads...")
+			cancel()
+		case <-ctx.Done():
+		}
 	}()
 
+	// One client for the whole session - a single listener, DHT node and
+	// peer table, shared by every torrent
+	client, err := newSessionClient(opts.Seed)
+	if err != nil {
+		logger.Log(true, "❌ Could not start torrent client: %v", err)
+		return results
+	}
+	defer closeWithLogs(client)
+
 	// Load metadata index once
 	metadataIndex := metadata.LoadMetadataCache()
 
+	cfg, err := shared.LoadConfig()
+	if err != nil {
+		logger.Log(true, "❌ Could not load config: %v", err)
+		return results
+	}
+
 	// Prepare all download metadata first
 	allTDs := []*shared.TorrentDownload{}
-	for _, entry := range entries {
+	for i, entry := range entries {
 		dKey := ui.StyleFactory(fmt.Sprintf("%4d", entry.DownloadKey), ui.Style.Pink)
 		title := ui.StyleFactory(entry.TorrentName, ui.Style.LBlue)
 
@@ -51,6 +113,13 @@ func HandleDownloadSession(entries []shared.TorrentEntry, outDir string, seed bo
 			TorrentID:    entry.TorrentID,
 			FullTitle:    entry.Title,
 			ChapterRange: entry.ChapterRange,
+			Quality:      entry.Quality,
+			Version:      entry.Version,
+			Extended:     entry.Extended,
+			Source:       entry.Source,
+			UpgradeOnly:  upgradeOnly[i],
+			MagnetLink:   entry.MagnetLink,
+			TorrentURL:   scraper.TorrentURL(cfg, entry),
 		}
 
 		shared.SaveTorrentDownload(td)
@@ -59,7 +128,17 @@ func HandleDownloadSession(entries []shared.TorrentEntry, outDir string, seed bo
 
 	// Start UI progress monitoring
 	doneChan := make(chan struct{})
-	go ui.FollowProgress(doneChan)
+	if !opts.Quiet {
+		go ui.FollowProgress(doneChan)
+	}
+
+	// Publish progress for 'status' in other terminals
+	stopPublish := make(chan struct{})
+	publishDone := make(chan struct{})
+	go func() {
+		defer close(publishDone)
+		publishProgress(stopPublish)
+	}()
 
 	// Create work queue
 	workQueue := make(chan int, len(entries))
@@ -73,7 +152,7 @@ func HandleDownloadSession(entries []shared.TorrentEntry, outDir string, seed bo
 
 	// Start worker goroutines
 	var wg sync.WaitGroup
-	for w := 0; w < MaxConcurrent; w++ {
+	for w := 0; w < MaxConcurrent(); w++ {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
@@ -84,9 +163,35 @@ func HandleDownloadSession(entries []shared.TorrentEntry, outDir string, seed bo
 				default:
 					td := allTDs[i]
 
-					// Download (and, if seed is set, keep uploading afterward
+					tmpBase, err := shared.GetTempDir()
+					if err != nil {
+						logger.Log(false, "failed to find temp dir: %v", err)
+					}
+					tmpDir := filepath.Join(tmpBase, fmt.Sprintf("opfor-tmp-%d", td.TorrentID))
+
+					// remember what this temp dir is for once the torrent is
+					// going, so an interrupted download can be picked up
+					// again by 'resume'. A bad magnet or link leaves nothing
+					onStarted := func() {
+						if err := saveResumeEntry(tmpDir, entries[i], upgradeOnly[i]); err != nil {
+							logger.Log(false, "Failed to save resume entry for %s: %v", td.Title, err)
+						}
+					}
+
+					// hardlinked files can go into the library as soon as
+					// they're downloaded and keep seeding from tmpDir
+					placed := false
+					var onSeeding func()
+					if opts.Seed && cfg.Placement == shared.PlacementHardlink {
+						onSeeding = func() {
+							matcher.ProcessTorrentFiles(tmpDir, outDir, td, metadataIndex)
+							placed = true
+						}
+					}
+
+					// Download (and, if Seed is set, keep uploading afterward
 					// until ctx is cancelled - StartTorrent blocks for that).
-					err := StartTorrent(ctx, td, seed)
+					err = StartTorrent(ctx, client, td, opts.Seed, onStarted, onSeeding)
 
 					// A cancel that arrives *after* the download already
 					// finished just means the user stopped a --seed session -
@@ -97,25 +202,23 @@ func HandleDownloadSession(entries []shared.TorrentEntry, outDir string, seed bo
 					if err != nil && !seedingStoppedAfterSuccess {
 						if err == context.DeadlineExceeded {
 							logger.Log(true, "Download timeout for %s (no progress in 30 min)", td.Title)
-							td.PlacementProgress = "❌ Timeout - no seeders?"
+							td.PlacementProgress = "❌ Timeout - no seeders? Use 'resume' to retry"
 						} else if err == context.Canceled {
-							td.PlacementProgress = "❌ Cancelled"
+							td.PlacementProgress = "❌ Cancelled - use 'resume' to continue"
 						} else {
 							logger.Log(true, "Download failed for %s: %v", td.Title, err)
 							td.PlacementProgress = "❌ Failed"
 						}
+						dropUnstarted(tmpDir)
 						shared.SaveTorrentDownload(td)
 						placementResults <- td
 						continue
 					}
 
-					tmpBase, err := shared.GetTempDir()
-					if err != nil {
-						logger.Log(false, "failed to find temp dir: %v", err)
-					}
 					// Place immediately after download completes
-					tmpDir := filepath.Join(tmpBase, fmt.Sprintf("opfor-tmp-%d", td.TorrentID))
-					matcher.ProcessTorrentFiles(tmpDir, outDir, td, metadataIndex)
+					if !placed {
+						matcher.ProcessTorrentFiles(tmpDir, outDir, td, metadataIndex)
+					}
 
 					// Clean up temp directory immediately
 					if err := os.RemoveAll(tmpDir); err != nil {
@@ -140,18 +243,27 @@ func HandleDownloadSession(entries []shared.TorrentEntry, outDir string, seed bo
 		placedTorrents = append(placedTorrents, td)
 	}
 
-	// Signal UI that downloads are done
-	doneChan <- struct{}{}
+	// Signal UI and publisher that downloads are done. A publish still
+	// running would write the state file again after it's cleared below
+	close(stopPublish)
+	<-publishDone
+	if !opts.Quiet {
+		doneChan <- struct{}{}
 
-	// Wait for UI to finish
-	select {
-	case <-doneChan:
-	case <-time.After(1 * time.Second):
-		// Don't wait forever for UI
+		// Wait for UI to finish
+		select {
+		case <-doneChan:
+		case <-time.After(1 * time.Second):
+			// Don't wait forever for UI
+		}
 	}
 
 	// Print placement results
 	for _, td := range placedTorrents {
+		if opts.Quiet {
+			fmt.Printf("🎞️  %s %s\n", td.Title, td.PlacementProgress)
+			continue
+		}
 		if len(td.PlacementFull) > 0 {
 			fmt.Printf("🎞️  %s\n", ui.AnsiPadRight(td.Title, 36, ".."))
 			for _, line := range td.PlacementFull {
@@ -160,6 +272,8 @@ func HandleDownloadSession(entries []shared.TorrentEntry, outDir string, seed bo
 		}
 	}
 
+	// temp dirs of placed torrents are already gone - whatever is left is
+	// partial data that 'resume' (or downloading the same key) continues from
 	shared.ClearActiveDownloads()
 
 	if ctx.Err() != nil {
@@ -167,4 +281,28 @@ func HandleDownloadSession(entries []shared.TorrentEntry, outDir string, seed bo
 	} else {
 		logger.Log(true, "\n✅ All downloads finished and placed.")
 	}
+
+	// failed and cancelled downloads never get to placement
+	for i, td := range allTDs {
+		results[i] = td.Placed
+	}
+	return results
+}
+
+// publishes the session state until stop is closed
+func publishProgress(stop chan struct{}) {
+	ticker := time.NewTicker(shared.PublishInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := shared.PublishActiveDownloads(); err != nil {
+			logger.Log(false, "Failed to publish download state: %v", err)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
 }
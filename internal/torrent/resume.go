@@ -0,0 +1,111 @@
+package torrent
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"opforjellyfin/internal/logger"
+	"opforjellyfin/internal/shared"
+)
+
+// resumeFileName holds the TorrentEntry a temp dir was started for, so an
+// interrupted download can be picked up again by 'resume' without the search
+// cache. Dotfiles are skipped by placement and by hasDownloadedData.
+const resumeFileName = ".opfor-entry.json"
+
+// Resumable is an interrupted download, with how it was started. The entry's
+// fields are stored flat, so files written before UpgradeOnly still load
+type Resumable struct {
+	shared.TorrentEntry
+	UpgradeOnly bool `json:"upgrade_only,omitempty"`
+}
+
+// saves the entry next to its partial data
+func saveResumeEntry(tmpDir string, entry shared.TorrentEntry, upgradeOnly bool) error {
+	data, err := json.MarshalIndent(Resumable{entry, upgradeOnly}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(tmpDir, resumeFileName), data, 0644)
+}
+
+// PendingResumes returns the entries of all interrupted downloads that still
+// have a temp dir, sorted by RawIndex (the first chapter, specials last).
+func PendingResumes() ([]Resumable, error) {
+	tmpBase, err := shared.GetTempDir()
+	if err != nil {
+		return nil, err
+	}
+
+	dirs, err := os.ReadDir(tmpBase)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []Resumable
+	for _, d := range dirs {
+		if !d.IsDir() || !strings.HasPrefix(d.Name(), "opfor-tmp-") {
+			continue
+		}
+
+		path := filepath.Join(tmpBase, d.Name(), resumeFileName)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			logger.Log(false, "resume: no entry for %s: %v", d.Name(), err)
+			continue
+		}
+
+		var entry Resumable
+		if err := json.Unmarshal(data, &entry); err != nil {
+			logger.Log(false, "resume: could not parse %s: %v", path, err)
+			continue
+		}
+
+		entries = append(entries, entry)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].RawIndex < entries[j].RawIndex
+	})
+
+	return entries, nil
+}
+
+// dropUnstarted removes tmpDir if no run ever got its torrent going: without
+// data or a resume entry there's nothing for 'resume' to pick up
+func dropUnstarted(tmpDir string) {
+	if hasDownloadedData(tmpDir) || shared.FileExists(filepath.Join(tmpDir, resumeFileName)) {
+		return
+	}
+	if err := os.RemoveAll(tmpDir); err != nil {
+		logger.Log(false, "Failed to remove temp dir %s: %v", tmpDir, err)
+	}
+}
+
+// hasDownloadedData reports whether a previous run left torrent data in tmpDir.
+// The piece completion db and the resume entry are dotfiles and don't count.
+func hasDownloadedData(tmpDir string) bool {
+	found := false
+	filepath.WalkDir(tmpDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || path == tmpDir {
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.IsDir() {
+			found = true
+			return filepath.SkipAll
+		}
+		return nil
+	})
+
+	return found
+}
@@ -0,0 +1,75 @@
+package torrent
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"opforjellyfin/internal/shared"
+)
+
+func TestPendingResumesKeepsUpgradeOnly(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	cfg, _ := shared.LoadConfig()
+	cfg.TargetDir = t.TempDir()
+
+	upgrade, err := shared.CreateTempTorrentDir(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := saveResumeEntry(upgrade, shared.TorrentEntry{TorrentID: 1, RawIndex: 1}, true); err != nil {
+		t.Fatal(err)
+	}
+
+	// written before the upgrade mode was recorded: a bare entry
+	old, _ := shared.CreateTempTorrentDir(2)
+	os.WriteFile(filepath.Join(old, resumeFileName), []byte(`{"TorrentID": 2, "RawIndex": 2, "Quality": "720p"}`), 0644)
+
+	pending, err := PendingResumes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 2 {
+		t.Fatalf("got %d pending, want 2", len(pending))
+	}
+	if !pending[0].UpgradeOnly || pending[0].TorrentID != 1 {
+		t.Errorf("upgrade lost: %+v", pending[0])
+	}
+	if pending[1].UpgradeOnly || pending[1].Quality != "720p" {
+		t.Errorf("old entry misread: %+v", pending[1])
+	}
+}
+
+func TestDropUnstarted(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	cfg, _ := shared.LoadConfig()
+	cfg.TargetDir = t.TempDir()
+
+	// the magnet never resolved: only the completion db
+	failed, _ := shared.CreateTempTorrentDir(1)
+	os.WriteFile(filepath.Join(failed, ".torrent.db"), nil, 0644)
+
+	// an earlier run got going, this one timed out on the metadata
+	started, _ := shared.CreateTempTorrentDir(2)
+	if err := saveResumeEntry(started, shared.TorrentEntry{TorrentID: 2}, false); err != nil {
+		t.Fatal(err)
+	}
+
+	dropUnstarted(failed)
+	dropUnstarted(started)
+
+	if _, err := os.Stat(failed); !os.IsNotExist(err) {
+		t.Error("temp dir of a torrent that never started kept")
+	}
+	pending, err := PendingResumes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 || pending[0].TorrentID != 2 {
+		t.Errorf("got pending %+v, want only torrent 2", pending)
+	}
+}
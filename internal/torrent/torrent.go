@@ -11,32 +11,64 @@ import (
 
 	"github.com/anacrolix/torrent"
 	"github.com/anacrolix/torrent/metainfo"
+	"github.com/anacrolix/torrent/storage"
+	"golang.org/x/time/rate"
 )
 
-// main torrent download and tracker. When seed is true, the client keeps
-// uploading after the download finishes until ctx is cancelled (Ctrl+C) -
-// the caller is responsible for not treating that as a failure.
-func StartTorrent(ctx context.Context, td *shared.TorrentDownload, seed bool) error {
-	config, err := shared.LoadConfig()
+// magnetInfoTimeout bounds how long a magnet may take to fetch the torrent's
+// info from peers, which is much slower than downloading a .torrent file.
+const magnetInfoTimeout = 2 * time.Minute
+
+// minRateBurst is the smallest burst given to a rate limiter. anacrolix needs
+// the burst to fit a whole chunk (16 KiB) or read, or transfers stall entirely.
+const minRateBurst = 256 * 1024
+
+// newSessionClient creates the one torrent client shared by every torrent in a
+// download session. Each torrent still gets its own storage (see StartTorrent),
+// so DataDir is only a fallback and never receives downloads itself.
+func newSessionClient(seed bool) (*torrent.Client, error) {
+	tmpBase, err := shared.GetTempDir()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	srcBaseURL := config.Source.BaseURL
-	// get torrent meta-info
-	torrentURL := fmt.Sprintf("%s/download/%d.torrent", srcBaseURL, td.TorrentID)
-	logger.Log(false, "Fetching torrent: %s, ID: %s", torrentURL, td.Title)
 
-	// get metadata
-	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, torrentURL, nil)
-	resp, err := http.DefaultClient.Do(req)
+	appCfg, err := shared.LoadConfig()
 	if err != nil {
-		logger.Log(false, "HTTP request for metadata failed %s", td.Title)
-		return err
+		return nil, err
 	}
-	defer resp.Body.Close()
+	dlCfg := appCfg.Download
 
-	//build meta
-	meta, err := metainfo.Load(resp.Body)
+	cfg := torrent.NewDefaultClientConfig()
+	cfg.DataDir = tmpBase
+	cfg.NoUpload = !seed
+	cfg.Seed = seed // upload even once we have nothing left to gain ourselves
+	cfg.ListenPort = dlCfg.ListenPort
+
+	if dlCfg.DownloadLimit > 0 {
+		cfg.DownloadRateLimiter = newRateLimiter(dlCfg.DownloadLimit)
+	}
+	if dlCfg.UploadLimit > 0 {
+		cfg.UploadRateLimiter = newRateLimiter(dlCfg.UploadLimit)
+	}
+
+	logger.Log(false, "Torrent client: port %d, down %d KiB/s, up %d KiB/s (0 = unlimited)", dlCfg.ListenPort, dlCfg.DownloadLimit, dlCfg.UploadLimit)
+
+	return torrent.NewClient(cfg)
+}
+
+// limiter for kib KiB/s, each token is one byte
+func newRateLimiter(kib int) *rate.Limiter {
+	bytesPerSec := kib * 1024
+	return rate.NewLimiter(rate.Limit(bytesPerSec), max(bytesPerSec, minRateBurst))
+}
+
+// main torrent download and tracker. When seed is true, the client keeps
+// uploading after the download finishes until ctx is cancelled (Ctrl+C) -
+// the caller is responsible for not treating that as a failure. onStarted, if
+// set, is called once the torrent is added and its metadata loaded, onSeeding
+// once the download is complete and seeding starts.
+func StartTorrent(ctx context.Context, client *torrent.Client, td *shared.TorrentDownload, seed bool, onStarted, onSeeding func()) error {
+	spec, err := torrentSpec(ctx, td)
 	if err != nil {
 		return err
 	}
@@ -47,36 +79,55 @@ func StartTorrent(ctx context.Context, td *shared.TorrentDownload, seed bool) er
 		return fmt.Errorf("failed to create temp dir: %w", err)
 	}
 
-	// start the torrent-client with optimized settings for concurrent downloads
-	cfg := torrent.NewDefaultClientConfig()
-	cfg.DataDir = tmpDir
-	cfg.NoUpload = !seed
-	cfg.Seed = seed // upload even once we have nothing left to gain ourselves
-	cfg.ListenPort = 0
+	// a previous run was interrupted - its data and piece completion db are
+	// still in tmpDir, so only what's missing needs fetching
+	resuming := hasDownloadedData(tmpDir)
 
-	client, err := torrent.NewClient(cfg)
-	if err != nil {
-		return err
-	}
-	defer closeWithLogs(client)
+	// per-torrent storage keeps data and piece completion db in tmpDir, as
+	// with a client of its own. Dropped before the storage is closed.
+	store := storage.NewFile(tmpDir)
+	defer store.Close()
+	spec.Storage = store
 
 	// add torrent
-	t, err := client.AddTorrent(meta)
+	t, isNew, err := client.AddTorrentSpec(spec)
 	if err != nil {
 		return err
 	}
+	if !isNew {
+		return fmt.Errorf("torrent is already downloading in this session")
+	}
+	defer t.Drop()
+
+	// get torrent metadata. A magnet has to fetch it from peers first
+	infoTimeout := 20 * time.Second
+	if td.MagnetLink != "" {
+		infoTimeout = magnetInfoTimeout
+	}
 
-	// get torrent metadata
 	select {
 	case <-t.GotInfo():
 		td.TotalSize = t.Length()
 		logger.Log(false, "Torrent metadata loaded: %s", td.Title)
-	case <-time.After(20 * time.Second):
+	case <-time.After(infoTimeout):
 		return fmt.Errorf("timeout waiting for torrent metadata")
 	case <-ctx.Done():
 		return ctx.Err()
 	}
 
+	if onStarted != nil {
+		onStarted()
+	}
+
+	// the completion db can't know whether files were touched since, so
+	// re-hash what's on disk before trusting it
+	if resuming {
+		logger.Log(false, "Resuming %s, verifying existing data", td.Title)
+		td.PlacementProgress = "♻️ Resuming, verifying existing data.."
+		shared.SaveTorrentDownload(td)
+		t.VerifyData()
+	}
+
 	// start download
 	td.TotalSize = t.Length()
 	t.DownloadAll()
@@ -97,6 +148,9 @@ func StartTorrent(ctx context.Context, td *shared.TorrentDownload, seed bool) er
 			return downloadCtx.Err()
 		case <-ticker.C:
 			td.Progress = t.BytesCompleted()
+			if resuming && td.Progress > 0 {
+				td.PlacementProgress = "♻️ Resumed"
+			}
 			shared.SaveTorrentDownload(td)
 		}
 	}
@@ -111,7 +165,12 @@ func StartTorrent(ctx context.Context, td *shared.TorrentDownload, seed bool) er
 	logger.Log(false, "Download complete: %s", td.Title)
 
 	if seed {
-		td.PlacementProgress = "🌱 Seeding until stopped (Ctrl+C)..."
+		if onSeeding != nil {
+			onSeeding()
+			td.PlacementProgress += " - 🌱 seeding until stopped (Ctrl+C)"
+		} else {
+			td.PlacementProgress = "🌱 Seeding until stopped (Ctrl+C)..."
+		}
 		shared.SaveTorrentDownload(td)
 		<-ctx.Done()
 		logger.Log(false, "Stopped seeding: %s", td.Title)
@@ -121,6 +180,41 @@ func StartTorrent(ctx context.Context, td *shared.TorrentDownload, seed bool) er
 	return nil
 }
 
+// torrentSpec builds the spec to add: from the magnet link when the entry has
+// one, otherwise from the .torrent file at td.TorrentURL.
+func torrentSpec(ctx context.Context, td *shared.TorrentDownload) (*torrent.TorrentSpec, error) {
+	if td.MagnetLink != "" {
+		logger.Log(false, "Adding magnet for %s: %s", td.Title, td.MagnetLink)
+		return torrent.TorrentSpecFromMagnetUri(td.MagnetLink)
+	}
+
+	// get torrent meta-info
+	logger.Log(false, "Fetching torrent: %s, ID: %s", td.TorrentURL, td.Title)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, td.TorrentURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		logger.Log(false, "HTTP request for metadata failed %s", td.Title)
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code fetching torrent: %d", resp.StatusCode)
+	}
+
+	//build meta
+	meta, err := metainfo.Load(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return torrent.TorrentSpecFromMetaInfoErr(meta)
+}
+
 // loghelper
 func closeWithLogs(client *torrent.Client) {
 	if client != nil {
@@ -0,0 +1,215 @@
+// watch/watch.go
+package watch
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"opforjellyfin/internal/metadata"
+	"opforjellyfin/internal/scraper"
+	"opforjellyfin/internal/shared"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const stateFileName = "watch.json"
+
+// stateVersion is bumped when fingerprints change, so the old ones are
+// relearned instead of every release looking new. 1: torrent IDs unique
+// across sources
+const stateVersion = 1
+
+// Subscription is something 'watch run' downloads new releases of
+type Subscription struct {
+	Arc     string `json:"arc,omitempty"`     // arc name, as for 'download --arc'. Empty = every new release
+	Quality string `json:"quality,omitempty"` // only this quality, empty = best available
+}
+
+func (s Subscription) String() string {
+	name := "all new releases"
+	if s.Arc != "" {
+		name = s.Arc
+	}
+	if s.Quality != "" {
+		name += " (" + s.Quality + ")"
+	}
+	return name
+}
+
+// State is the watchlist and what has been seen on the sources so far
+type State struct {
+	Version       int            `json:"version"`
+	Subscriptions []Subscription `json:"subscriptions"`
+
+	// releases seen by fingerprint, with when they were last listed on a
+	// source. A release not in here is new, or an updated upload of a known
+	// one
+	Seen map[string]time.Time `json:"seen"`
+}
+
+// SeenRetention is how long a release no source lists anymore is remembered
+const SeenRetention = 90 * 24 * time.Hour
+
+func statePath() string {
+	return filepath.Join(shared.ConfigDir(), stateFileName)
+}
+
+// Load reads the watch state, an empty one if there is none yet
+func Load() (*State, error) {
+	state := &State{Version: stateVersion, Seen: make(map[string]time.Time)}
+
+	data, err := os.ReadFile(statePath())
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, err
+	}
+	// the next round learns what's out there again, like the first one
+	if state.Seen == nil || state.Version < stateVersion {
+		state.Seen = make(map[string]time.Time)
+		state.Version = stateVersion
+	}
+
+	return state, nil
+}
+
+// Save writes the state via a temp file, so a crash never leaves half of it
+func (s *State) Save() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return shared.WriteFileAtomic(statePath(), data, 0644)
+}
+
+// Add subscribes, returns false if already subscribed
+func (s *State) Add(sub Subscription) bool {
+	for _, existing := range s.Subscriptions {
+		if strings.EqualFold(existing.Arc, sub.Arc) && existing.Quality == sub.Quality {
+			return false
+		}
+	}
+	s.Subscriptions = append(s.Subscriptions, sub)
+	return true
+}
+
+// Remove unsubscribes from arc (any quality), "" for all new releases.
+// Returns the number of subscriptions removed
+func (s *State) Remove(arc string) int {
+	kept := s.Subscriptions[:0]
+	for _, sub := range s.Subscriptions {
+		if !strings.EqualFold(sub.Arc, arc) {
+			kept = append(kept, sub)
+		}
+	}
+
+	removed := len(s.Subscriptions) - len(kept)
+	s.Subscriptions = kept
+	return removed
+}
+
+// Unseen returns the entries not seen before. Nothing is marked, see MarkSeen
+func (s *State) Unseen(entries []shared.TorrentEntry) []shared.TorrentEntry {
+	var unseen []shared.TorrentEntry
+	for _, e := range entries {
+		if _, ok := s.Seen[Fingerprint(e)]; !ok {
+			unseen = append(unseen, e)
+		}
+	}
+
+	return unseen
+}
+
+// MarkSeen remembers entries, so they aren't new anymore
+func (s *State) MarkSeen(entries ...shared.TorrentEntry) {
+	now := time.Now()
+	for _, e := range entries {
+		s.Seen[Fingerprint(e)] = now
+	}
+}
+
+// Refresh notes that the seen ones among entries are still listed
+func (s *State) Refresh(entries []shared.TorrentEntry) {
+	now := time.Now()
+	for _, e := range entries {
+		fp := Fingerprint(e)
+		if _, ok := s.Seen[fp]; ok {
+			s.Seen[fp] = now
+		}
+	}
+}
+
+// Expire forgets releases not listed for longer than SeenRetention. Only
+// call it after a round every source answered, or an outage would expire
+// everything it lists
+func (s *State) Expire() {
+	for fp, listed := range s.Seen {
+		if time.Since(listed) > SeenRetention {
+			delete(s.Seen, fp)
+		}
+	}
+}
+
+// Fingerprint identifies an upload, as opposed to a release: a re-upload of
+// the same chapter range and quality gets a new title or torrent id and
+// counts as an update
+func Fingerprint(e shared.TorrentEntry) string {
+	return fmt.Sprintf("%s|%d", e.Title, e.TorrentID)
+}
+
+// Select picks what to download for sub out of entries: the best release per
+// chapter range of the arc, or of everything (specials included) for an
+// all-releases subscription
+func (sub Subscription) Select(entries []shared.TorrentEntry) []shared.TorrentEntry {
+	var candidates []shared.TorrentEntry
+	for _, e := range entries {
+		if sub.Quality == "" || e.Quality == sub.Quality {
+			candidates = append(candidates, e)
+		}
+	}
+
+	if sub.Arc == "" {
+		return append(scraper.SelectForRange(candidates, 1, math.MaxInt32, ""), bestSpecials(candidates)...)
+	}
+
+	var selected []shared.TorrentEntry
+	for _, season := range metadata.FindSeasonsByName(sub.Arc) {
+		lo, hi := shared.ParseRange(season.Range)
+		selected = append(selected, scraper.SelectForRange(candidates, lo, hi, "")...)
+	}
+
+	return selected
+}
+
+// the best quality upload of each special among entries
+func bestSpecials(entries []shared.TorrentEntry) []shared.TorrentEntry {
+	best := make(map[string]int)
+	var specials []shared.TorrentEntry
+
+	for _, e := range entries {
+		if !e.IsSpecial {
+			continue
+		}
+		name := strings.ToLower(e.TorrentName)
+		i, ok := best[name]
+		if !ok {
+			best[name] = len(specials)
+			specials = append(specials, e)
+			continue
+		}
+		cur := specials[i]
+		if q, cq := shared.QualityValue(e.Quality), shared.QualityValue(cur.Quality); q > cq || (q == cq && e.Seeders > cur.Seeders) {
+			specials[i] = e
+		}
+	}
+
+	return specials
+}
@@ -0,0 +1,75 @@
+package watch
+
+import (
+	"testing"
+	"time"
+
+	"opforjellyfin/internal/shared"
+)
+
+func TestUnseenAndSelect(t *testing.T) {
+	state := &State{Seen: make(map[string]time.Time)}
+
+	old := shared.TorrentEntry{Title: "[One Pace][1-7] Romance Dawn [1080p]", TorrentID: 1, ChapterRange: "1-7", Quality: "1080p", Seeders: 5}
+	if got := state.Unseen([]shared.TorrentEntry{old}); len(got) != 1 {
+		t.Fatalf("first sight: got %d unseen, want 1", len(got))
+	}
+	if got := state.Unseen([]shared.TorrentEntry{old}); len(got) != 1 {
+		t.Fatalf("not marked yet: got %d unseen, want 1", len(got))
+	}
+	state.MarkSeen(old)
+
+	reupload := old
+	reupload.TorrentID = 2
+	fresh := []shared.TorrentEntry{
+		old,
+		reupload,
+		{Title: "[One Pace][8-11] Orange Town [720p]", TorrentID: 3, ChapterRange: "8-11", Quality: "720p", Seeders: 5},
+		{Title: "[One Pace][8-11] Orange Town [1080p]", TorrentID: 4, ChapterRange: "8-11", Quality: "1080p", Seeders: 1},
+		{Title: "[One Pace] Cover Stories [720p]", TorrentID: 5, TorrentName: "Cover Stories", IsSpecial: true, Quality: "720p", Seeders: 5},
+	}
+
+	unseen := state.Unseen(fresh)
+	if len(unseen) != 4 {
+		t.Fatalf("got %d unseen, want the 4 new uploads", len(unseen))
+	}
+	state.MarkSeen(unseen...)
+	if again := state.Unseen(fresh); len(again) != 0 {
+		t.Errorf("got %d unseen on the second look, want 0", len(again))
+	}
+
+	var ids []int
+	for _, e := range (Subscription{}).Select(unseen) {
+		ids = append(ids, e.TorrentID)
+	}
+	want := []int{2, 4, 5}
+	if len(ids) != len(want) {
+		t.Fatalf("selected %v, want %v", ids, want)
+	}
+	for i := range want {
+		if ids[i] != want[i] {
+			t.Fatalf("selected %v, want %v", ids, want)
+		}
+	}
+}
+
+func TestExpire(t *testing.T) {
+	listed := shared.TorrentEntry{Title: "[One Pace][1-7] Romance Dawn [1080p]", TorrentID: 1}
+	gone := shared.TorrentEntry{Title: "[One Pace][8-11] Orange Town [720p]", TorrentID: 3}
+
+	longAgo := time.Now().Add(-SeenRetention - time.Hour)
+	state := &State{Seen: map[string]time.Time{
+		Fingerprint(listed): longAgo,
+		Fingerprint(gone):   longAgo,
+	}}
+
+	state.Refresh([]shared.TorrentEntry{listed})
+	state.Expire()
+
+	if _, ok := state.Seen[Fingerprint(listed)]; !ok {
+		t.Error("a release still listed was forgotten")
+	}
+	if _, ok := state.Seen[Fingerprint(gone)]; ok {
+		t.Error("a release no longer listed was kept past SeenRetention")
+	}
+}